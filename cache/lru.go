@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+/*
+	This package has a bounded least-recently-used cache.
+	Entries carry a size, eg: the cells of a matrix, and the least recently used
+	are evicted once the sizes add up past the capacity.
+*/
+
+// Stats are a cache's counters and occupancy.
+type Stats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Entries  int    `json:"entries"`
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+}
+
+// LRU is a size-bounded least-recently-used cache, safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	order    *list.List // front is most recently used
+	items    map[K]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int
+}
+
+// Returns an empty LRU holding entries whose sizes add up to at most capacity.
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{capacity: capacity, order: list.New(), items: map[K]*list.Element{}}
+}
+
+// Returns the value under key, marking it most recently used, and counts a hit or miss.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		var zero V
+		return zero, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*entry[K, V]).value, true
+}
+
+// Stores value under key, evicting the least recently used entries to make room.
+// A value larger than the whole capacity is not stored.
+func (c *LRU[K, V]) Add(key K, value V, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size > c.capacity {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, size: size})
+	c.size += size
+	for c.size > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Returns the hit and miss counters and current occupancy.
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Hits: c.hits, Misses: c.misses, Entries: len(c.items), Size: c.size, Capacity: c.capacity}
+}
+
+// Removes an entry. Called with c.mu held.
+func (c *LRU[K, V]) remove(el *list.Element) {
+	e := c.order.Remove(el).(*entry[K, V])
+	delete(c.items, e.key)
+	c.size -= e.size
+}
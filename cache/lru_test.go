@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestLRU checks eviction order, size accounting and the hit and miss counters.
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	c.Add("a", 1, 4)
+	c.Add("b", 2, 4)
+	c.Get("a")       // a is now more recently used than b
+	c.Add("c", 3, 4) // evicts b
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if got, ok := c.Get(key); !ok || got != want {
+			t.Fatalf("expected %s=%d, got %d %v", key, want, got, ok)
+		}
+	}
+
+	c.Add("huge", 4, 11) // larger than the capacity, never stored
+	c.Add("a", 5, 2)     // replacing an entry updates its size
+	if got, _ := c.Get("a"); got != 5 {
+		t.Fatalf("expected a replaced, got %d", got)
+	}
+
+	want := Stats{Hits: 4, Misses: 1, Entries: 2, Size: 6, Capacity: 10}
+	if got := c.Stats(); got != want {
+		t.Fatalf("stats mismatch: want %+v got %+v", want, got)
+	}
+}
+
+// TestLRUConcurrent exercises the cache from many goroutines, for the race detector.
+func TestLRUConcurrent(t *testing.T) {
+	c := NewLRU[int, int](50)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				c.Add((g*i)%100, i, 1)
+				c.Get(i % 100)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := c.Stats(); s.Entries > 50 || s.Hits+s.Misses != 8000 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
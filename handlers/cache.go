@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"league_challenge/cache"
+	"league_challenge/matrix"
+	"league_challenge/middleware"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+	This file has the result cache and conditional requests.
+	A result is keyed on a hash of the operation name, the query parameters that
+	shape it and the canonical parsed inputs: dimensions, labels and trimmed
+	cells, so the same matrix uploaded as csv, JSON or ?id= shares one entry.
+	The ETag is that key plus the response type, a client sending it back in
+	If-None-Match gets a 304 without the operation being run.
+	Only uploads taking the in-memory path are cached, streamed ones are not.
+*/
+
+// ResultCache holds operation results by resultKey, sized in cells.
+type ResultCache = cache.LRU[string, *matrix.Matrix[string]]
+
+// defaultCacheCells bounds the result cache Register creates when Config.Cache is nil.
+const defaultCacheCells = 1 << 20
+
+// Returns the cache key of op's result over in: a sha256 of the operation name,
+// the query without ?id= and each input's dimensions, labels and cells.
+// Every field is length prefixed so no two inputs share an encoding.
+func resultKey(op Operation, in []*matrix.Matrix[string], query url.Values) string {
+	h := sha256.New()
+	writeField(h, op.Name)
+	params := url.Values{}
+	for k, v := range query {
+		if k != "id" {
+			params[k] = v
+		}
+	}
+	writeField(h, params.Encode()) // sorted by key
+	for _, m := range in {
+		binary.Write(h, binary.BigEndian, [2]uint64{uint64(m.Rows), uint64(m.Cols)})
+		for _, labels := range [][]string{m.RowLabels, m.ColLabels} {
+			binary.Write(h, binary.BigEndian, uint64(len(labels)))
+			for _, label := range labels {
+				writeField(h, label)
+			}
+		}
+		for _, row := range m.Data {
+			for _, cell := range row {
+				writeField(h, cell)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeField(h hash.Hash, s string) {
+	binary.Write(h, binary.BigEndian, uint64(len(s)))
+	io.WriteString(h, s)
+}
+
+// Returns the strong ETag of the result under key written as contentType.
+func etagFor(key, contentType string) string {
+	sum := sha256.Sum256([]byte(key + "\x00" + contentType))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Reports whether an If-None-Match header matches etag, using the weak comparison
+// RFC 9110 asks for, so W/"x" matches "x".
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs op over in, or returns its cached result. Reports "hit" or "miss", also
+// to the access log, or "" without a cache. Errors are never cached.
+func (op Operation) cached(r *http.Request, key string, in []*matrix.Matrix[string]) (*matrix.Matrix[string], string, error) {
+	c := configFrom(r.Context()).Cache
+	if c == nil {
+		result, err := op.apply(r.Context(), in, r.URL.Query())
+		return result, "", err
+	}
+
+	status := "hit"
+	result, ok := c.Get(key)
+	if !ok {
+		status = "miss"
+		var err error
+		if result, err = op.apply(r.Context(), in, r.URL.Query()); err != nil {
+			return nil, status, err
+		}
+		c.Add(key, result, result.Rows*result.Cols)
+	}
+	middleware.AddAttrs(r.Context(), slog.String("cache", status))
+	return result, status, nil
+}
+
+// Writes the result cache's hit and miss counters and occupancy.
+func cacheStats(c *ResultCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.Stats())
+	}
+}
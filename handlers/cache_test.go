@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"league_challenge/cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestResultCache runs a sequence of requests against one mux, checking which
+// are served from the cache, which get a 304 and that ETags follow the result.
+func TestResultCache(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{StreamAbove: 1 << 20})
+
+	upload := func(target, content string) *http.Request {
+		return newMultipartRequest(t, target, &content)
+	}
+	asJSON := func(target, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	etags := map[string]string{}
+	tests := []struct {
+		name        string
+		req         *http.Request
+		accept      string
+		ifNoneMatch string // name of an earlier step whose ETag is sent
+		wantCode    int
+		wantCache   string
+		sameETagAs  string // name of an earlier step with the same ETag, "" for a new one
+		wantBody    string
+	}{
+		{name: "first upload", req: upload("/transpose", "1,2\n3,4\n"), wantCode: http.StatusOK, wantCache: "miss", wantBody: "1,3\n2,4\n"},
+		{name: "same upload", req: upload("/transpose", "1,2\n3,4\n"), wantCode: http.StatusOK, wantCache: "hit", sameETagAs: "first upload", wantBody: "1,3\n2,4\n"},
+		{name: "spacing is canonical", req: upload("/transpose", "1, 2\n 3,4\n"), wantCode: http.StatusOK, wantCache: "hit", sameETagAs: "first upload"},
+		{name: "inverse", req: upload("/inverse", "2,0\n0,4\n"), wantCode: http.StatusOK, wantCache: "miss", wantBody: "1/2,0\n0,1/4\n"},
+		{name: "alias", req: upload("/invert", "2,0\n0,4\n"), wantCode: http.StatusOK, wantCache: "hit", sameETagAs: "inverse"},
+		{name: "json upload", req: asJSON("/transpose", `{"matrix": [[1,2],[3,4]]}`), wantCode: http.StatusOK, wantCache: "hit", sameETagAs: "first upload"},
+		{name: "json response", req: upload("/transpose", "1,2\n3,4\n"), accept: "application/json", wantCode: http.StatusOK, wantCache: "hit", wantBody: `"value":[[1,3],[2,4]]`},
+		{name: "other operation", req: upload("/flatten", "1,2\n3,4\n"), wantCode: http.StatusOK, wantCache: "miss", wantBody: "1,2,3,4"},
+		{name: "other matrix", req: upload("/transpose", "1,2\n3,5\n"), wantCode: http.StatusOK, wantCache: "miss"},
+		{name: "not modified", req: upload("/transpose", "1,2\n3,4\n"), ifNoneMatch: "first upload", wantCode: http.StatusNotModified, sameETagAs: "first upload"},
+		{name: "stale etag", req: upload("/transpose", "1,2\n3,4\n"), ifNoneMatch: "other matrix", wantCode: http.StatusOK, wantCache: "hit", sameETagAs: "first upload"},
+		{name: "add", req: upload("/add", "1,2\n3,4\n"), wantCode: http.StatusOK, wantCache: "miss", wantBody: "10"},
+		{name: "add big", req: upload("/add?precision=big", "1,2\n3,4\n"), wantCode: http.StatusOK, wantCache: "miss", wantBody: "10"},
+		{name: "error", req: upload("/inverse", "1,2\n2,4\n"), wantCode: http.StatusUnprocessableEntity, wantCache: "", wantBody: "singular"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.accept != "" {
+				tc.req.Header.Set("Accept", tc.accept)
+			}
+			if tc.ifNoneMatch != "" {
+				tc.req.Header.Set("If-None-Match", etags[tc.ifNoneMatch])
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, tc.req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("X-Cache"); got != tc.wantCache {
+				t.Fatalf("expected X-Cache %q, got %q", tc.wantCache, got)
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, rec.Body.String())
+			}
+
+			etag := rec.Header().Get("ETag")
+			etags[tc.name] = etag
+			switch {
+			case tc.wantCode >= 400:
+				if etag != "" {
+					t.Fatalf("expected no ETag on an error, got %s", etag)
+				}
+			case tc.sameETagAs != "":
+				if etag != etags[tc.sameETagAs] {
+					t.Fatalf("expected the ETag of %q, %s, got %s", tc.sameETagAs, etags[tc.sameETagAs], etag)
+				}
+			default:
+				for name, other := range etags {
+					if name != tc.name && other == etag {
+						t.Fatalf("expected a new ETag, got the one of %q", name)
+					}
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cache", nil))
+	var stats cache.Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid /cache body %q: %v", rec.Body.String(), err)
+	}
+	if stats.Hits != 6 || stats.Misses != 7 || stats.Entries != 6 {
+		t.Fatalf("expected 6 hits, 7 misses and 6 entries, got %+v", stats)
+	}
+}
+
+// TestResultCacheStreamed checks large uploads still stream, without an ETag.
+func TestResultCacheStreamed(t *testing.T) {
+	content := "1,2\n3,4\n"
+	rec := httptest.NewRecorder()
+	newTestMux().ServeHTTP(rec, newMultipartRequest(t, "/add", &content))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "10" {
+		t.Fatalf("expected 200 with 10, got %d %q", rec.Code, rec.Body.String())
+	}
+	if etag, status := rec.Header().Get("ETag"), rec.Header().Get("X-Cache"); etag != "" || status != "" {
+		t.Fatalf("expected a streamed response without ETag or X-Cache, got %q %q", etag, status)
+	}
+}
+
+// TestETagMatches checks If-None-Match lists, wildcards and weak tags.
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: `"abc"`, want: true},
+		{header: `W/"abc"`, want: true},
+		{header: `"x", "abc"`, want: true},
+		{header: `*`, want: true},
+		{header: `"abcd"`, want: false},
+		{header: `abc`, want: false},
+		{header: ``, want: false},
+	}
+	for _, tc := range tests {
+		if got := etagMatches(tc.header, `"abc"`); got != tc.want {
+			t.Fatalf("etagMatches(%q) = %v, expected %v", tc.header, got, tc.want)
+		}
+	}
+}
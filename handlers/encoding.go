@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"league_challenge/matrix"
+	"league_challenge/middleware"
+	"league_challenge/store"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	This file has the request and response formats.
+	Matrices are uploaded as multipart csv files by default, or as a JSON body:
+		{"matrix": [[1,2],[3,4]]}             single input operations
+		{"a": [[1,2]], "b": [[3],[4]]}        operations with named inputs, eg: /matmul
+	or referenced once stored under /matrices, eg: /matmul?id=3f2a9c0b1d4e5f60&id=...
+	The csv dialect of form files is sniffed, or given in the query or a CSV-Dialect header:
+		/add?delimiter=semicolon&header=true
+		/transpose?header=true&labels=true    keeps the header row and first column as labels
+		CSV-Dialect: delimiter=tab; comment=#; lazy_quotes=true
+	Results are written as csv by default, or as JSON with Accept: application/json
+		{"kind": "matrix", "shape": [2,2], "value": [[1,2],[3,4]]}
+*/
+
+// requestTypes are the content types matrices can be uploaded as.
+var requestTypes = []string{"multipart/form-data", "application/json"}
+
+// responseTypes are the content types results can be written as, in order of preference.
+var responseTypes = []string{"text/csv", "application/json"}
+
+// jsonResult is the JSON response body for a result of any kind.
+// shape is [rows, cols] for a matrix, [length] for a vector and [] for a scalar.
+// A labelled matrix also has row_labels and/or col_labels.
+type jsonResult struct {
+	Kind      string   `json:"kind"`
+	Shape     []int    `json:"shape"`
+	Value     any      `json:"value"`
+	RowLabels []string `json:"row_labels,omitempty"`
+	ColLabels []string `json:"col_labels,omitempty"`
+}
+
+// Loads the matrices named by keys from the store with ?id=, or from either a
+// JSON body or multipart form files.
+// Reports the loaded sizes, eg: "3x3" or "2x3,3x2", to the access log.
+func loadInputs(r *http.Request, keys []string) ([]*matrix.Matrix[string], error) {
+	var in []*matrix.Matrix[string]
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case r.URL.Query().Has("id"):
+		in, err = loadStored(r, keys)
+	case mediaType == "application/json":
+		in, err = loadJSON(r, keys)
+	default:
+		in, err = loadForm(r, keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make([]string, len(in))
+	for i, m := range in {
+		sizes[i] = fmt.Sprintf("%dx%d", m.Rows, m.Cols)
+		observeSize(r.Context(), m.Rows, m.Cols)
+	}
+	middleware.AddAttrs(r.Context(), slog.String("matrix_size", strings.Join(sizes, ",")))
+	return in, nil
+}
+
+// Loads one multipart form file per key, streamed within the route's limits.
+func loadForm(r *http.Request, keys []string) ([]*matrix.Matrix[string], error) {
+	d, err := dialectFor(r)
+	if err != nil {
+		return nil, err
+	}
+	return matrix.NewMatricesFromForm[string](r, keys, d, configFrom(r.Context()).Limits)
+}
+
+// dialectHeader names the request header giving the csv dialect, as ; separated
+// key=value pairs, eg: "delimiter=semicolon; header=true"
+const dialectHeader = "CSV-Dialect"
+
+// delimiterNames are the names a delimiter or comment character may be given by,
+// for characters awkward in a URL or a header.
+var delimiterNames = map[string]rune{"comma": ',', "semicolon": ';', "tab": '\t', "pipe": '|', "space": ' ', "hash": '#'}
+
+// Returns the csv dialect of the form uploads: delimiter, comment, lazy_quotes,
+// header and labels from the query, falling back to the CSV-Dialect header. The zero
+// Dialect, sniffing the delimiter, if neither has them.
+func dialectFor(r *http.Request) (matrix.Dialect, error) {
+	params := map[string]string{}
+	for _, pair := range strings.Split(r.Header.Get(dialectHeader), ";") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			params[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	for _, k := range []string{"delimiter", "comment", "lazy_quotes", "header", "labels"} {
+		if r.URL.Query().Has(k) {
+			params[k] = r.URL.Query().Get(k)
+		}
+	}
+
+	var d matrix.Dialect
+	var err error
+	if v, ok := params["delimiter"]; ok {
+		if d.Delimiter, err = dialectRune("delimiter", v); err != nil {
+			return d, err
+		}
+	}
+	if v, ok := params["comment"]; ok {
+		if d.Comment, err = dialectRune("comment", v); err != nil {
+			return d, err
+		}
+	}
+	if v, ok := params["lazy_quotes"]; ok {
+		if d.LazyQuotes, err = strconv.ParseBool(v); err != nil {
+			return d, fmt.Errorf("error: lazy_quotes must be true or false, got '%s'", v)
+		}
+	}
+	if v, ok := params["header"]; ok {
+		if d.Header, err = strconv.ParseBool(v); err != nil {
+			return d, fmt.Errorf("error: header must be true or false, got '%s'", v)
+		}
+	}
+	if v, ok := params["labels"]; ok {
+		if d.Labels, err = strconv.ParseBool(v); err != nil {
+			return d, fmt.Errorf("error: labels must be true or false, got '%s'", v)
+		}
+	}
+	return d, d.Validate()
+}
+
+// Returns the character v names, eg: "tab" or ";".
+func dialectRune(key, v string) (rune, error) {
+	if r, ok := delimiterNames[strings.ToLower(v)]; ok {
+		return r, nil
+	}
+	if utf8.RuneCountInString(v) != 1 {
+		return 0, fmt.Errorf("error: %s must be a single character or one of comma, semicolon, tab, pipe, space, hash. got '%s'", key, v)
+	}
+	r, _ := utf8.DecodeRuneInString(v)
+	return r, nil
+}
+
+// Loads stored matrices, one ?id= per key in keys order, eg: /matmul?id=a1&id=b2
+func loadStored(r *http.Request, keys []string) ([]*matrix.Matrix[string], error) {
+	ids := r.URL.Query()["id"]
+	if len(ids) != len(keys) {
+		return nil, fmt.Errorf("error: got %d ?id= parameters, expected one per input (%s)", len(ids), strings.Join(keys, ","))
+	}
+	s := configFrom(r.Context()).Store
+	if s == nil {
+		return nil, store.ErrNotFound
+	}
+
+	in := make([]*matrix.Matrix[string], len(ids))
+	for i, id := range ids {
+		m, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		in[i] = m
+	}
+	return in, nil
+}
+
+// Decodes a JSON body holding one array of rows per input, and nothing after it.
+// The body is bounded by the route's byte limit, rows are checked once decoded.
+func loadJSON(r *http.Request, keys []string) ([]*matrix.Matrix[string], error) {
+	var body map[string]json.RawMessage
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("error: invalid JSON body. %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("error: invalid JSON body. must be a single JSON object, found more after it")
+	}
+
+	limits := configFrom(r.Context()).Limits
+	in := make([]*matrix.Matrix[string], 0, len(keys))
+	for _, key := range keys {
+		key = jsonKey(key)
+		raw, ok := body[key]
+		if !ok {
+			return nil, fmt.Errorf("error: JSON body must have key '%s'", key)
+		}
+		m, err := matrix.FromJSON[string](raw)
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range m.Data {
+			if err := limits.CheckRow(i+1, row); err != nil {
+				return nil, err
+			}
+		}
+		in = append(in, m)
+	}
+	return in, nil
+}
+
+// Returns the JSON body key for a form file key, the single "file" upload is "matrix".
+func jsonKey(formKey string) string {
+	if formKey == "file" {
+		return "matrix"
+	}
+	return formKey
+}
+
+// Writes a result in the negotiated content type.
+func render(w io.Writer, contentType string, m *matrix.Matrix[string], kind Kind) {
+	if contentType == "application/json" {
+		renderJSON(w, m, kind)
+		return
+	}
+	renderCSV(w, m, kind)
+}
+
+// Writes a result as csv: matrices one row per line, vectors and scalars on a single line.
+func renderCSV(w io.Writer, m *matrix.Matrix[string], kind Kind) {
+	if kind == KindMatrix {
+		fmt.Fprint(w, m.Echo())
+		return
+	}
+	fmt.Fprint(w, m.Flatten())
+}
+
+// Writes a result as a jsonResult.
+func renderJSON(w io.Writer, m *matrix.Matrix[string], kind Kind) {
+	json.NewEncoder(w).Encode(jsonResultFor(m, kind))
+}
+
+// Returns the jsonResult for a result. Cells that are valid JSON numbers are
+// written as numbers, anything else (eg: "1/2", "foo") as strings.
+func jsonResultFor(m *matrix.Matrix[string], kind Kind) jsonResult {
+	rows := make([][]any, m.Rows)
+	for i, row := range m.Data {
+		rows[i] = make([]any, len(row))
+		for j, cell := range row {
+			rows[i][j] = jsonCell(cell)
+		}
+	}
+
+	result := jsonResult{Kind: kind.String()}
+	switch kind {
+	case KindMatrix:
+		result.Shape, result.Value = []int{m.Rows, m.Cols}, rows
+		result.RowLabels, result.ColLabels = m.RowLabels, m.ColLabels
+	case KindVector:
+		result.Shape, result.Value = []int{m.Cols}, rows[0]
+	case KindScalar:
+		result.Shape, result.Value = []int{}, rows[0][0]
+	}
+	return result
+}
+
+// Returns cell as a json.Number if it is a valid JSON number literal.
+func jsonCell(cell string) any {
+	if cell == "" || !(cell[0] == '-' || ('0' <= cell[0] && cell[0] <= '9')) || !json.Valid([]byte(cell)) {
+		return cell
+	}
+	return json.Number(cell)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestJSONRequests posts JSON bodies and checks both the csv default and the
+// JSON response for matrix, vector and scalar results.
+func TestJSONRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		accept   string
+		wantCode int
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "csv response by default",
+			target:   "/transpose",
+			body:     `{"matrix": [[1,2],[3,4]]}`,
+			wantCode: http.StatusOK,
+			wantType: "text/csv",
+			wantBody: "1,3\n2,4\n",
+		},
+		{
+			name:     "matrix",
+			target:   "/transpose",
+			body:     `{"matrix": [[1,2,3],[4,5,6]]}`,
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"kind":"matrix","shape":[3,2],"value":[[1,4],[2,5],[3,6]]}` + "\n",
+		},
+		{
+			name:     "vector",
+			target:   "/flatten",
+			body:     `{"matrix": [["a","b"],["c","d"]]}`,
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"kind":"vector","shape":[4],"value":["a","b","c","d"]}` + "\n",
+		},
+		{
+			name:     "scalar",
+			target:   "/add",
+			body:     `{"matrix": [[1,2],[3,4]]}`,
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"kind":"scalar","shape":[],"value":10}` + "\n",
+		},
+		{
+			name:     "fractions stay strings",
+			target:   "/inverse",
+			body:     `{"matrix": [[2,1],[4,3]]}`,
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"kind":"matrix","shape":[2,2],"value":[["3/2","-1/2"],[-2,1]]}` + "\n",
+		},
+		{
+			name:     "named inputs",
+			target:   "/matmul",
+			body:     `{"a": [[1,2]], "b": [[3],[4]]}`,
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"kind":"matrix","shape":[1,1],"value":[[11]]}` + "\n",
+		},
+		{
+			name:     "pipeline",
+			target:   "/pipeline?ops=transpose,flatten",
+			body:     `{"matrix": [[1,2],[3,4]]}`,
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"kind":"vector","shape":[4],"value":[1,3,2,4]}` + "\n",
+		},
+		{
+			name:     "missing key",
+			target:   "/matmul",
+			body:     `{"a": [[1,2]]}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "JSON body must have key 'b'",
+		},
+		{
+			name:     "invalid body",
+			target:   "/echo",
+			body:     `{"matrix": [[1,2]`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid JSON body",
+		},
+		{
+			name:     "trailing garbage",
+			target:   "/echo",
+			body:     `{"matrix": [[1]]} garbage`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid JSON body. must be a single JSON object",
+		},
+		{
+			name:     "two objects",
+			target:   "/echo",
+			body:     `{"matrix": [[1]]}` + "\n" + `{"matrix": [[2]]}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid JSON body. must be a single JSON object",
+		},
+		{
+			name:     "trailing whitespace",
+			target:   "/echo",
+			body:     `{"matrix": [[1]]}` + "\n\n",
+			wantCode: http.StatusOK,
+			wantBody: "1\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			newTestMux().ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if tc.wantType != "" && rec.Header().Get("Content-Type") != tc.wantType {
+				t.Fatalf("expected content type %q, got %q", tc.wantType, rec.Header().Get("Content-Type"))
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, body)
+			}
+		})
+	}
+}
+
+// TestJSONResponseFromUpload checks a multipart csv upload can still ask for
+// a JSON response.
+func TestJSONResponseFromUpload(t *testing.T) {
+	content := sampleMatrixCSV
+	req := newMultipartRequest(t, "/mul", &content)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+
+	newTestMux().ServeHTTP(rec, req)
+
+	want := `{"kind":"scalar","shape":[],"value":362880}` + "\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Fatalf("expected 200 %q, got %d %q", want, rec.Code, rec.Body.String())
+	}
+}
+
+// TestCSVDialects uploads the same matrix in several dialects, given in the
+// query, the CSV-Dialect header or sniffed, on the streamed and in-memory paths.
+func TestCSVDialects(t *testing.T) {
+	mux := newTestMux()
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		content  string
+		wantCode int
+		wantBody string
+	}{
+		{name: "sniffed semicolon", target: "/add", content: "1;2\n3;4\n", wantCode: http.StatusOK, wantBody: "10"},
+		{name: "sniffed tab", target: "/transpose", content: "1\t2\n3\t4\n", wantCode: http.StatusOK, wantBody: "1,3\n2,4\n"},
+		{name: "byte order mark", target: "/det", content: "\ufeff1,2\n3,4\n", wantCode: http.StatusOK, wantBody: "-2"},
+		{name: "query", target: "/echo?delimiter=%3B&comment=%23&header=true", content: "a;b\n# note\n1;2\t3\n4;5\t6\n", wantCode: http.StatusOK, wantBody: "1,2\t3\n4,5\t6\n"},
+		{name: "dialect header", target: "/flatten", header: "delimiter=pipe; header=true", content: "a|b\n1|2\n", wantCode: http.StatusOK, wantBody: "1,2"},
+		{name: "query over header", target: "/flatten?delimiter=tab", header: "delimiter=pipe", content: "1\t2\n", wantCode: http.StatusOK, wantBody: "1,2"},
+		{name: "lazy quotes", target: "/echo?lazy_quotes=true", content: "1,\"2\"3\n", wantCode: http.StatusOK, wantBody: "1,\"2\"\"3\n\"\n"},
+		{name: "decimal commas echoed", target: "/echo", content: "1;2,5\n3;4,5\n", wantCode: http.StatusOK, wantBody: "1,\"2,5\"\n3,\"4,5\"\n"},
+		{name: "decimal commas flattened", target: "/flatten", content: "1;2,5\n3;4,5\n", wantCode: http.StatusOK, wantBody: "1,\"2,5\",3,\"4,5\""},
+		{name: "decimal commas transposed", target: "/transpose", content: "1;2,5\n3;4,5\n", wantCode: http.StatusOK, wantBody: "1,3\n\"2,5\",\"4,5\"\n"},
+		{name: "pipeline", target: "/pipeline?ops=transpose&delimiter=semicolon", content: "1;2\n", wantCode: http.StatusOK, wantBody: "1\n2\n"},
+		{name: "long delimiter", target: "/echo?delimiter=%3B%3B", content: "1\n", wantCode: http.StatusBadRequest, wantBody: "delimiter must be a single character"},
+		{name: "bad header flag", target: "/add?header=maybe", content: "1\n", wantCode: http.StatusBadRequest, wantBody: "header must be true or false"},
+		{name: "invalid delimiter", target: "/echo?delimiter=%22", content: "1\n", wantCode: http.StatusBadRequest, wantBody: "invalid delimiter"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, tc.target, &tc.content)
+			if tc.header != "" {
+				req.Header.Set(dialectHeader, tc.header)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestLabels uploads a labelled confusion matrix, checking labels are kept
+// through transpose, written by echo, in JSON responses and in stored matrices,
+// and ignored by numeric operations.
+func TestLabels(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{StreamAbove: 1 << 20})
+	const labelled = ",cat,dog\ncat,5,1\ndog,2,7\n"
+
+	tests := []struct {
+		name     string
+		target   string
+		content  string
+		accept   string
+		stream   bool
+		wantBody string
+	}{
+		{name: "echo", target: "/echo?header=true&labels=true", content: labelled, wantBody: labelled},
+		{name: "echo streamed", target: "/echo?header=true&labels=true", content: labelled, stream: true, wantBody: labelled},
+		{name: "transpose", target: "/transpose?header=true&labels=true", content: ",a,b\nx,1,2\n", wantBody: ",x\na,1\nb,2\n"},
+		{name: "same cells unlabelled", target: "/transpose", content: "1,2\n", wantBody: "1\n2\n"},
+		{name: "json", target: "/transpose?header=true&labels=true", content: ",a,b\nx,1,2\n", accept: "application/json", wantBody: `"row_labels":["a","b"],"col_labels":["x"]`},
+		{name: "add", target: "/add?header=true&labels=true", content: labelled, wantBody: "15"},
+		{name: "add streamed", target: "/add?header=true&labels=true", content: labelled, stream: true, wantBody: "15"},
+		{name: "det", target: "/det?header=true&labels=true", content: labelled, wantBody: "33"},
+		{name: "pipeline", target: "/pipeline?ops=transpose,rotate90&header=true&labels=true", content: ",a,b\nx,1,2\ny,3,4\n", wantBody: ",b,a\nx,2,1\ny,4,3\n"},
+		{name: "dialect header", target: "/echo", content: "x,1\n", wantBody: "x,1\n"},
+		{name: "bad flag", target: "/echo?labels=yes-please", content: labelled, wantBody: "labels must be true or false"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, tc.target, &tc.content)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if tc.name == "dialect header" {
+				req.Header.Set(dialectHeader, "labels=true")
+			}
+			if tc.stream {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %d %q", tc.wantBody, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// stored labels come back with the matrix
+	content := labelled
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, newMultipartRequest(t, "/matrices?header=true&labels=true", &content))
+	id := rec.Body.String()
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/matrices/"+id, nil))
+	if rec.Body.String() != labelled {
+		t.Fatalf("expected the stored labelled matrix, got %q", rec.Body.String())
+	}
+}
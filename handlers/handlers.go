@@ -1,135 +1,156 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
 	"league_challenge/matrix"
-	"log"
-	"net/http"
+	"math/big"
+	"strconv"
 )
 
-// Print back the matrix
-func Echo(w http.ResponseWriter, r *http.Request) {
-
-	// before and after logging
-	log.Printf("REQUEST: method=%v on url=%v from remote=%v", r.Method, r.URL.Path, r.RemoteAddr)
-	reqStatus := http.StatusBadRequest
-	defer func() {
-		log.Printf("RESPONSE: status=%d on method=%v on url=%v from %v", reqStatus, r.Method, r.URL.Path, r.RemoteAddr)
-	}()
-
-	// get matrix
-	matrix, err := matrix.NewMatrix(r)
-	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
-	}
-
-	// write and return response
-	w.Header().Set("Content-Type", "text/csv")
-	reqStatus = http.StatusOK
-	w.WriteHeader(reqStatus)
-	fmt.Fprint(w, matrix.Echo())
+// operations is every matrix operation the service exposes.
+// Adding an entry here gives it a route, a /pipeline step and a /help line.
+var operations = []Operation{
+	{
+		Name:    "echo",
+		Summary: "prints back the matrix",
+		Element: Text,
+		Result:  KindMatrix,
+		Run: as(unary(func(_ context.Context, m *matrix.Matrix[string]) (*matrix.Matrix[string], error) {
+			return m, nil
+		})),
+		Stream: rowwise(matrix.ScanEcho),
+	},
+	{
+		Name:    "transpose",
+		Summary: "rows become columns, columns become rows",
+		Element: Text,
+		Result:  KindMatrix,
+		Run: as(unary(func(_ context.Context, m *matrix.Matrix[string]) (*matrix.Matrix[string], error) {
+			return m.TransposeParallel(0), nil
+		})),
+	},
+	{
+		Name:    "rotate90",
+		Summary: "rotates the matrix 90 degrees clockwise",
+		Element: Text,
+		Result:  KindMatrix,
+		Run: as(unary(func(_ context.Context, m *matrix.Matrix[string]) (*matrix.Matrix[string], error) {
+			return m.Rotate90(), nil
+		})),
+	},
+	{
+		Name:    "flatten",
+		Summary: "all values in row order on a single line",
+		Element: Text,
+		Result:  KindVector,
+		Run: as(unary(func(_ context.Context, m *matrix.Matrix[string]) (*matrix.Matrix[string], error) {
+			flat := make([]string, 0, m.Rows*m.Cols)
+			for _, row := range m.Data {
+				flat = append(flat, row...)
+			}
+			return &matrix.Matrix[string]{Data: [][]string{flat}, Rows: 1, Cols: len(flat)}, nil
+		})),
+		Stream: rowwise(matrix.ScanFlatten),
+	},
+	{
+		Name:    "inverse",
+		Aliases: []string{"invert"},
+		Summary: "exact inverse, cells written as fractions eg: 1/2",
+		Shape:   Square,
+		Element: Rational,
+		Result:  KindMatrix,
+		Run: formatted(as(unary(func(ctx context.Context, m *matrix.Matrix[*big.Rat]) (*matrix.Matrix[string], error) {
+			inverse, err := m.InverseContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return inverse.Strings(), nil
+		}))),
+	},
+	{
+		Name:    "add",
+		Summary: "sum of all values",
+		Element: Integer,
+		Result:  KindScalar,
+		Skip:    "0",
+		Run:     formatted(integers(sum[int64], sum[*big.Int], sum[float64], sum[*big.Rat])),
+		Stream:  scanIntegers(matrix.ScanAddPromoted[int64, float64], matrix.ScanAddPromoted[*big.Int, *big.Rat]),
+	},
+	{
+		Name:    "mul",
+		Summary: "product of all values",
+		Element: Integer,
+		Result:  KindScalar,
+		Skip:    "1",
+		Run:     formatted(integers(cellProduct[int64], cellProduct[*big.Int], cellProduct[float64], cellProduct[*big.Rat])),
+		Stream:  scanIntegers(matrix.ScanMultiplyPromoted[int64, float64], matrix.ScanMultiplyPromoted[*big.Int, *big.Rat]),
+	},
+	{
+		Name:    "matmul",
+		Summary: "matrix product AxB of uploads 'a' and 'b'",
+		Inputs:  []string{"a", "b"},
+		Element: Integer,
+		Result:  KindMatrix,
+		Run:     formatted(integers(product[int64], product[*big.Int], product[float64], product[*big.Rat])),
+	},
+	{
+		Name:    "det",
+		Summary: "exact determinant",
+		Shape:   Square,
+		Element: Rational,
+		Result:  KindScalar,
+		Run: formatted(as(unary(func(ctx context.Context, m *matrix.Matrix[*big.Rat]) (*matrix.Matrix[string], error) {
+			det, err := m.DeterminantContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return scalar(det.RatString()), nil
+		}))),
+	},
+	{
+		Name:    "trace",
+		Summary: "sum of the main diagonal",
+		Shape:   Square,
+		Element: Integer,
+		Result:  KindScalar,
+		Skip:    "0",
+		Run:     formatted(integers(trace[int64], trace[*big.Int], trace[float64], trace[*big.Rat])),
+	},
+	{
+		Name:    "rank",
+		Summary: "number of linearly independent rows",
+		Element: Rational,
+		Result:  KindScalar,
+		Run: as(unary(func(ctx context.Context, m *matrix.Matrix[*big.Rat]) (*matrix.Matrix[string], error) {
+			rank, err := m.RankContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return scalar(strconv.Itoa(rank)), nil
+		})),
+	},
 }
 
-// Invert/Transpose a NxN matrix...rows become columns, columns become rows
-func Transpose(w http.ResponseWriter, r *http.Request) {
-
-	log.Printf("REQUEST: method=%v on url=%v from remote=%v", r.Method, r.URL.Path, r.RemoteAddr)
-	reqStatus := http.StatusBadRequest
-	defer func() {
-		log.Printf("RESPONSE: status=%d on method=%v on url=%v from %v", reqStatus, r.Method, r.URL.Path, r.RemoteAddr)
-	}()
-
-	matrix, err := matrix.NewMatrix(r)
-	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
-	}
-
-	// call to actually mutate (transpose) the matrix
-	matrix.Transpose()
-
-	// write and return response
-	w.Header().Set("Content-Type", "text/csv")
-	reqStatus = http.StatusOK
-	w.WriteHeader(reqStatus)
-	fmt.Fprint(w, matrix.Echo())
+// Sums every cell, on GOMAXPROCS workers.
+func sum[T matrix.Number](ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error) {
+	return reduction(allCPUs((*matrix.Matrix[T]).AddParallel))(ctx, in)
 }
 
-// Returns the flattened representation of the matrix
-func Flatten(w http.ResponseWriter, r *http.Request) {
-
-	log.Printf("REQUEST: method=%v on url=%v from remote=%v", r.Method, r.URL.Path, r.RemoteAddr)
-	reqStatus := http.StatusBadRequest
-	defer func() {
-		log.Printf("RESPONSE: status=%d on method=%v on url=%v from %v", reqStatus, r.Method, r.URL.Path, r.RemoteAddr)
-	}()
-
-	matrix, err := matrix.NewMatrix(r)
-	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
-	}
-
-	reqStatus = http.StatusOK
-	w.Header().Set("Content-Type", "text/csv")
-	w.WriteHeader(reqStatus)
-	// flattened response returned here
-	fmt.Fprint(w, matrix.Flatten())
+// Multiplies every cell, on GOMAXPROCS workers.
+func cellProduct[T matrix.Number](ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error) {
+	return reduction(allCPUs((*matrix.Matrix[T]).MultiplyParallel))(ctx, in)
 }
 
-// Returns the sum of all values in a matrix
-func Addition(w http.ResponseWriter, r *http.Request) {
-
-	log.Printf("REQUEST: method=%v on url=%v from remote=%v", r.Method, r.URL.Path, r.RemoteAddr)
-	reqStatus := http.StatusBadRequest
-	defer func() {
-		log.Printf("RESPONSE: status=%d on method=%v on url=%v from %v", reqStatus, r.Method, r.URL.Path, r.RemoteAddr)
-	}()
-
-	matrix, err := matrix.NewMatrix(r)
-	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
-	}
-
-	// calculate sum
-	sum, err := matrix.Add()
-	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
-	}
-
-	reqStatus = http.StatusOK
-	w.Header().Set("Content-Type", "text/csv")
-	w.WriteHeader(reqStatus)
-	fmt.Fprint(w, sum)
+// Sums the main diagonal.
+func trace[T matrix.Number](ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error) {
+	return reduction(quick((*matrix.Matrix[T]).Trace))(ctx, in)
 }
 
-// Returns the product of all values in a matrix
-func Multiply(w http.ResponseWriter, r *http.Request) {
-
-	log.Printf("REQUEST: method=%v on url=%v from remote=%v", r.Method, r.URL.Path, r.RemoteAddr)
-	reqStatus := http.StatusBadRequest
-	defer func() {
-		log.Printf("RESPONSE: status=%d on method=%v on url=%v from %v", reqStatus, r.Method, r.URL.Path, r.RemoteAddr)
-	}()
-
-	matrix, err := matrix.NewMatrix(r)
+// Multiplies the two inputs, A×B.
+func product[T matrix.Number](ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error) {
+	prod, err := in[0].MatMulContext(ctx, in[1])
 	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
+		return nil, err
 	}
-
-	// calculate product
-	prod, err := matrix.Multiply()
-	if err != nil {
-		http.Error(w, err.Error(), reqStatus)
-		return
-	}
-
-	reqStatus = http.StatusOK
-	w.Header().Set("Content-Type", "text/csv")
-	w.WriteHeader(reqStatus)
-	fmt.Fprint(w, prod)
+	return prod.Strings(), nil
 }
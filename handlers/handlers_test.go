@@ -23,7 +23,7 @@ func TestHandlersSuccess(t *testing.T) {
 			req := newMultipartRequest(t, tc.target, &tc.input)
 			rec := httptest.NewRecorder()
 
-			tc.handler.ServeHTTP(rec, req)
+			newTestMux().ServeHTTP(rec, req)
 
 			if rec.Code != http.StatusOK {
 				t.Fatalf("expected status 200, got %d", rec.Code)
@@ -44,7 +44,7 @@ func TestHandlersMissingFile(t *testing.T) {
 			req := newMultipartRequest(t, tc.target, nil)
 			rec := httptest.NewRecorder()
 
-			tc.handler.ServeHTTP(rec, req)
+			newTestMux().ServeHTTP(rec, req)
 
 			if rec.Code != http.StatusBadRequest {
 				t.Fatalf("expected status 400, got %d", rec.Code)
@@ -66,7 +66,7 @@ func TestHandlersEmptyFile(t *testing.T) {
 			req := newMultipartRequest(t, tc.target, &content)
 			rec := httptest.NewRecorder()
 
-			tc.handler.ServeHTTP(rec, req)
+			newTestMux().ServeHTTP(rec, req)
 
 			if rec.Code != http.StatusBadRequest {
 				t.Fatalf("expected status 400, got %d", rec.Code)
@@ -88,7 +88,7 @@ func TestHandlersMalformedFile(t *testing.T) {
 			req := newMultipartRequest(t, tc.target, &malformed)
 			rec := httptest.NewRecorder()
 
-			tc.handler.ServeHTTP(rec, req)
+			newTestMux().ServeHTTP(rec, req)
 
 			if rec.Code != http.StatusBadRequest {
 				t.Fatalf("expected status 400, got %d", rec.Code)
@@ -107,14 +107,12 @@ func TestHandlersNonInt(t *testing.T) {
 	content := "1,2\n3,foo\n"
 	tests := []handlerExpectation{
 		{
-			name:    "addition",
-			target:  "/add",
-			handler: http.HandlerFunc(Addition),
+			name:   "addition",
+			target: "/add",
 		},
 		{
-			name:    "multiply",
-			target:  "/mul",
-			handler: http.HandlerFunc(Multiply),
+			name:   "multiply",
+			target: "/mul",
 		},
 	}
 
@@ -123,7 +121,7 @@ func TestHandlersNonInt(t *testing.T) {
 			req := newMultipartRequest(t, tc.target, &content)
 			rec := httptest.NewRecorder()
 
-			tc.handler.ServeHTTP(rec, req)
+			newTestMux().ServeHTTP(rec, req)
 
 			if rec.Code != http.StatusBadRequest {
 				t.Fatalf("expected status 400, got %d", rec.Code)
@@ -136,10 +134,224 @@ func TestHandlersNonInt(t *testing.T) {
 	}
 }
 
+// TestFloats checks decimal and scientific cells promote the arithmetic
+// endpoints, in memory and streamed, and that ?decimals= and ?digits= round results.
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		content  string
+		stream   bool
+		wantCode int
+		wantBody string
+	}{
+		{name: "add", target: "/add", content: "1,1.5\n2e3,0.25\n", wantCode: http.StatusOK, wantBody: "2002.75"},
+		{name: "add streamed", target: "/add", content: "1,1.5\n2e3,0.25\n", stream: true, wantCode: http.StatusOK, wantBody: "2002.75"},
+		{name: "mul", target: "/mul", content: "1.5,2\n", wantCode: http.StatusOK, wantBody: "3"},
+		{name: "ints stay exact", target: "/add", content: "9007199254740993,0\n", wantCode: http.StatusOK, wantBody: "9007199254740993"},
+		{name: "trace", target: "/trace", content: "0.5,9\n9,0.25\n", wantCode: http.StatusOK, wantBody: "0.75"},
+		{name: "big is exact", target: "/add?precision=big", content: "0.1,0.2\n", wantCode: http.StatusOK, wantBody: "3/10"},
+		{name: "big streamed", target: "/add?precision=big", content: "0.1,0.2\n", stream: true, wantCode: http.StatusOK, wantBody: "3/10"},
+		{name: "decimals", target: "/add?decimals=2", content: "0.1,0.2\n", wantCode: http.StatusOK, wantBody: "0.30"},
+		{name: "decimals streamed", target: "/add?decimals=2", content: "0.1,0.2\n", stream: true, wantCode: http.StatusOK, wantBody: "0.30"},
+		{name: "digits", target: "/mul?digits=3", content: "12345,1\n", wantCode: http.StatusOK, wantBody: "1.23e+04"},
+		{name: "inverse decimals", target: "/inverse?decimals=3", content: "3,0\n0,3\n", wantCode: http.StatusOK, wantBody: "0.333,0.000\n0.000,0.333\n"},
+		{name: "det digits", target: "/det?digits=2", content: "1.25,0\n0,1\n", wantCode: http.StatusOK, wantBody: "1.3"},
+		{name: "row mean not rounded", target: "/add?missing=row-mean", content: "1,2,\n0.5,0.5,0.5\n", wantCode: http.StatusOK, wantBody: "6"},
+		{name: "non-numeric", target: "/add", content: "1.5,six\n", wantCode: http.StatusBadRequest, wantBody: "non-float values in matrix. row 1, col 2"},
+		{name: "nan", target: "/add", content: "nan,1\n", wantCode: http.StatusBadRequest, wantBody: `"code":"non-numeric-cell"`},
+		{name: "infinity streamed", target: "/add", content: "1.5,inf\n", stream: true, wantCode: http.StatusBadRequest, wantBody: `"value":"inf"`},
+		{name: "float overflow", target: "/mul", content: "1e308,10\n", wantCode: http.StatusUnprocessableEntity, wantBody: `"code":"overflow"`},
+		{name: "float overflow streamed", target: "/mul", content: "1e308,10\n", stream: true, wantCode: http.StatusUnprocessableEntity, wantBody: `"code":"overflow"`},
+		{name: "cell too large", target: "/add", content: "9223372036854775808,-1\n", wantCode: http.StatusUnprocessableEntity, wantBody: "use precision=big"},
+		{name: "cell too large streamed", target: "/add", content: "99999999999999999999,1\n", stream: true, wantCode: http.StatusUnprocessableEntity, wantBody: "use precision=big"},
+		{name: "cell too large big", target: "/add?precision=big", content: "9223372036854775808,-1\n", wantCode: http.StatusOK, wantBody: "9223372036854775807"},
+		{name: "float overflow big", target: "/mul?precision=big", content: "1e308,10\n", wantCode: http.StatusOK, wantBody: "1" + strings.Repeat("0", 309)},
+		{name: "both formats", target: "/add?decimals=1&digits=1", content: "1\n", wantCode: http.StatusBadRequest, wantBody: "give one of decimals or digits"},
+		{name: "bad decimals", target: "/add?decimals=-1", content: "1\n", wantCode: http.StatusBadRequest, wantBody: "decimals must be a whole number from 0 to 100"},
+		{name: "bad digits streamed", target: "/add?digits=0", content: "1\n", stream: true, wantCode: http.StatusBadRequest, wantBody: "digits must be a whole number from 1 to 100"},
+	}
+
+	mux := http.NewServeMux()
+	Register(mux, Config{StreamAbove: 1 << 20})
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, tc.target, &tc.content)
+			if tc.stream {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestHandlersRectangular confirms MxN uploads are accepted by the structural
+// and arithmetic endpoints, while inverse rejects them as non-square.
+func TestHandlersRectangular(t *testing.T) {
+	content := "1,2,3\n4,5,6\n"
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{"transpose", "/transpose", http.StatusOK, "1,4\n2,5\n3,6\n"},
+		{"flatten", "/flatten", http.StatusOK, "1,2,3,4,5,6"},
+		{"addition", "/add", http.StatusOK, "21"},
+		{"rank", "/rank", http.StatusOK, "2"},
+		{"invert", "/invert", http.StatusBadRequest, "requires a square matrix, got 2x3"},
+		{"determinant", "/det", http.StatusBadRequest, "requires a square matrix, got 2x3"},
+		{"trace", "/trace", http.StatusBadRequest, "requires a square matrix, got 2x3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, tc.target, &content)
+			rec := httptest.NewRecorder()
+
+			newTestMux().ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d", tc.wantCode, rec.Code)
+			}
+
+			if body := rec.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, body)
+			}
+		})
+	}
+}
+
+// TestInverseSingular checks that a well-formed but singular matrix is
+// reported as unprocessable rather than as a malformed upload.
+func TestInverseSingular(t *testing.T) {
+	content := sampleMatrixCSV
+	req := newMultipartRequest(t, "/invert", &content)
+	rec := httptest.NewRecorder()
+
+	newTestMux().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", rec.Code)
+	}
+
+	if body := rec.Body.String(); !strings.Contains(body, "singular") {
+		t.Fatalf("expected singular matrix error, got %q", body)
+	}
+}
+
+// TestHandlersOverflow checks arithmetic endpoints refuse to return a wrapped
+// int64 result and produce the exact answer when precision=big is requested.
+func TestHandlersOverflow(t *testing.T) {
+	content := "9223372036854775807,1\n1,1\n"
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "addition overflows",
+			target:   "/add",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "precision=big",
+		},
+		{
+			name:     "addition big",
+			target:   "/add?precision=big",
+			wantCode: http.StatusOK,
+			wantBody: "9223372036854775810",
+		},
+		{
+			name:     "multiply big",
+			target:   "/mul?precision=big",
+			wantCode: http.StatusOK,
+			wantBody: "9223372036854775807",
+		},
+		{
+			name:     "unknown precision",
+			target:   "/mul?precision=huge",
+			wantCode: http.StatusBadRequest,
+			wantBody: "unknown precision",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, tc.target, &content)
+			rec := httptest.NewRecorder()
+
+			newTestMux().ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d", tc.wantCode, rec.Code)
+			}
+
+			if body := rec.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, body)
+			}
+		})
+	}
+}
+
+// TestMatrixProduct covers the two-file upload contract: the A×B product on
+// success and client errors for missing files or mismatched dimensions.
+func TestMatrixProduct(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "product",
+			files:    map[string]string{"a": "1,2\n3,4\n", "b": "5,6\n7,8\n"},
+			wantCode: http.StatusOK,
+			wantBody: "19,22\n43,50\n",
+		},
+		{
+			name:     "missing b",
+			files:    map[string]string{"a": "1,2\n3,4\n"},
+			wantCode: http.StatusBadRequest,
+			wantBody: "must upload form file with key 'b'",
+		},
+		{
+			name:     "inner dimensions",
+			files:    map[string]string{"a": "1,2\n3,4\n", "b": sampleMatrixCSV},
+			wantCode: http.StatusBadRequest,
+			wantBody: "inner dimensions do not match",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartFilesRequest(t, "/matmul", tc.files)
+			rec := httptest.NewRecorder()
+
+			newTestMux().ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d", tc.wantCode, rec.Code)
+			}
+
+			if body := rec.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, body)
+			}
+		})
+	}
+}
+
 type handlerExpectation struct {
 	name     string
 	target   string
-	handler  http.HandlerFunc
 	input    string
 	wantBody string
 }
@@ -151,63 +363,95 @@ func handlerExpectations() []handlerExpectation {
 		{
 			name:     "echo",
 			target:   "/echo",
-			handler:  http.HandlerFunc(Echo),
 			input:    sampleMatrixCSV,
 			wantBody: "1,2,3\n4,5,6\n7,8,9\n",
 		},
 		{
 			name:     "transpose",
 			target:   "/transpose",
-			handler:  http.HandlerFunc(Transpose),
 			input:    sampleMatrixCSV,
 			wantBody: "1,4,7\n2,5,8\n3,6,9\n",
 		},
 		{
 			name:     "invert",
 			target:   "/invert",
-			handler:  http.HandlerFunc(Transpose),
-			input:    sampleMatrixCSV,
-			wantBody: "1,4,7\n2,5,8\n3,6,9\n",
+			input:    "2,1\n4,3\n",
+			wantBody: "3/2,-1/2\n-2,1\n",
 		},
 		{
 			name:     "flatten",
 			target:   "/flatten",
-			handler:  http.HandlerFunc(Flatten),
 			input:    sampleMatrixCSV,
 			wantBody: "1,2,3,4,5,6,7,8,9",
 		},
 		{
 			name:     "addition",
 			target:   "/add",
-			handler:  http.HandlerFunc(Addition),
 			input:    sampleMatrixCSV,
 			wantBody: "45",
 		},
 		{
 			name:     "multiply",
 			target:   "/mul",
-			handler:  http.HandlerFunc(Multiply),
 			input:    sampleMatrixCSV,
 			wantBody: "362880",
 		},
+		{
+			name:     "determinant",
+			target:   "/det",
+			input:    "2,1\n4,3\n",
+			wantBody: "2",
+		},
+		{
+			name:     "trace",
+			target:   "/trace",
+			input:    sampleMatrixCSV,
+			wantBody: "15",
+		},
+		{
+			name:     "rank",
+			target:   "/rank",
+			input:    sampleMatrixCSV,
+			wantBody: "2",
+		},
 	}
 }
 
+// newTestMux returns a mux with every registered route, so tests exercise the
+// same routing as main.
+func newTestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	Register(mux, Config{})
+	return mux
+}
+
 // newMultipartRequest builds a POST request that optionally attaches a matrix
 // file, enabling success and failure cases to share a single helper.
 func newMultipartRequest(t *testing.T, target string, content *string) *http.Request {
 	t.Helper()
 
+	files := map[string]string{}
+	if content != nil {
+		files["file"] = *content
+	}
+	return newMultipartFilesRequest(t, target, files)
+}
+
+// newMultipartFilesRequest builds a POST request attaching one matrix file per
+// form key, for operations that take more than one matrix.
+func newMultipartFilesRequest(t *testing.T, target string, files map[string]string) *http.Request {
+	t.Helper()
+
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
-	if content != nil {
-		part, err := writer.CreateFormFile("file", "matrix.csv")
+	for key, content := range files {
+		part, err := writer.CreateFormFile(key, key+".csv")
 		if err != nil {
 			t.Fatalf("failed to create form file: %v", err)
 		}
 
-		if _, err := io.Copy(part, strings.NewReader(*content)); err != nil {
+		if _, err := io.Copy(part, strings.NewReader(content)); err != nil {
 			t.Fatalf("failed to write file contents: %v", err)
 		}
 	}
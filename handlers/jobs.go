@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"league_challenge/jobs"
+	"league_challenge/matrix"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+	This file has the /jobs resource, running operations in the background:
+		POST   /jobs?op=det     queues op on the upload (or ?id=), 202 with the job
+		GET    /jobs/{id}       status, progress and, once done, the result or problem
+		DELETE /jobs/{id}       cancels a queued or running job, removes a finished one
+	The upload is read while the request lasts, the operation runs on cfg.Jobs.
+	Progress is reported by the matrix operations as they go, see matrix.WithProgress.
+*/
+
+// jobStatus is the JSON body describing a job.
+// result is a jsonResult once done, error a problem once failed.
+type jobStatus struct {
+	ID       string      `json:"id"`
+	Op       string      `json:"op"`
+	Status   jobs.Status `json:"status"`
+	Progress float64     `json:"progress"`
+	Result   any         `json:"result,omitempty"`
+	Error    *problem    `json:"error,omitempty"`
+	Created  time.Time   `json:"created"`
+	Started  *time.Time  `json:"started,omitempty"`
+	Finished *time.Time  `json:"finished,omitempty"`
+}
+
+// Registers the /jobs routes over cfg.Jobs.
+func registerJobs(mux *http.ServeMux, cfg Config) {
+	mux.Handle("POST /jobs", cfg.metrics.instrument("jobs", withConfig(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitJob(w, r, cfg.Jobs, cfg.JobTimeout)
+	}), cfg)))
+	mux.Handle("OPTIONS /jobs", options([]string{"file"}))
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := cfg.Jobs.Get(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemFor(err))
+			return
+		}
+		writeJob(w, r, http.StatusOK, job)
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		cancelJob(w, r, cfg.Jobs)
+	})
+}
+
+// Loads the inputs of ?op= and queues the operation, responding 202 with the job.
+func submitJob(w http.ResponseWriter, r *http.Request, q *jobs.Queue, timeout time.Duration) {
+	name := r.URL.Query().Get("op")
+	op, ok := lookup(name)
+	if !ok {
+		writeProblem(w, r, problemFor(fmt.Errorf("error: unknown operation '%s'. pass ?op= with one of %s", name, strings.Join(operationNames(), ","))))
+		return
+	}
+	in, missing, err := op.load(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	reportMissing(w, r, missing)
+
+	query := r.URL.Query()
+	job, err := q.Submit(op.Name, func(ctx context.Context, progress func(float64)) (any, error) {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		ctx = matrix.WithProgress(ctx, func(done, total int) {
+			progress(float64(done) / float64(total))
+		})
+
+		result, err := op.apply(ctx, in, query)
+		if err != nil {
+			return nil, err
+		}
+		return jsonResultFor(result, op.Result), nil
+	})
+	if err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJob(w, r, http.StatusAccepted, job)
+}
+
+// Cancels a queued or running job, responding with it, or removes a finished one.
+func cancelJob(w http.ResponseWriter, r *http.Request, q *jobs.Queue) {
+	before, err := q.Get(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	job, err := q.Cancel(before.ID)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	if before.Stopped() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJob(w, r, http.StatusOK, job)
+}
+
+// Writes job as a jobStatus.
+func writeJob(w http.ResponseWriter, r *http.Request, status int, job jobs.Job) {
+	body := jobStatus{
+		ID:       job.ID,
+		Op:       job.Name,
+		Status:   job.Status,
+		Progress: job.Progress,
+		Result:   job.Result,
+		Created:  job.Created,
+		Started:  optionalTime(job.Started),
+		Finished: optionalTime(job.Finished),
+	}
+	if job.Err != nil {
+		body.Error = problemFor(job.Err)
+		body.Error.Instance = "/jobs/" + job.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Returns nil for the zero time, so unset times are left out of JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"league_challenge/jobs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// pollJob GETs the job until it stops, failing the test after a second.
+func pollJob(t *testing.T, mux *http.ServeMux, location string) jobStatus {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location, nil))
+		var status jobStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("expected job status, got %d %q", rec.Code, rec.Body.String())
+		}
+		if status.Status != jobs.Queued && status.Status != jobs.Running {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job at %s did not finish", location)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestJobs submits operations as jobs and polls them to their result or problem.
+func TestJobs(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{JobTimeout: time.Minute})
+
+	tests := []struct {
+		name       string
+		target     string
+		input      string
+		wantStatus jobs.Status
+		wantValue  any
+		wantCode   string
+	}{
+		{name: "det", target: "/jobs?op=det", input: "2,1\n1,1\n", wantStatus: jobs.Done, wantValue: 1.0},
+		{name: "alias", target: "/jobs?op=invert", input: "2,0\n0,4\n", wantStatus: jobs.Done, wantValue: []any{[]any{"1/2", 0.0}, []any{0.0, "1/4"}}},
+		{name: "precision", target: "/jobs?op=mul&precision=big", input: "9223372036854775807,2\n", wantStatus: jobs.Done, wantValue: 18446744073709551614.0},
+		{name: "singular", target: "/jobs?op=inverse", input: sampleMatrixCSV, wantStatus: jobs.Failed, wantCode: "singular"},
+		{name: "shape", target: "/jobs?op=det", input: "1,2\n", wantStatus: jobs.Failed, wantCode: "not-square"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, newMultipartRequest(t, tc.target, &tc.input))
+			if rec.Code != http.StatusAccepted || rec.Header().Get("Location") == "" {
+				t.Fatalf("expected 202 with a Location, got %d %q", rec.Code, rec.Body.String())
+			}
+
+			status := pollJob(t, mux, rec.Header().Get("Location"))
+			if status.Status != tc.wantStatus {
+				t.Fatalf("expected %s, got %+v", tc.wantStatus, status)
+			}
+			if tc.wantCode != "" {
+				if status.Error == nil || status.Error.Code != tc.wantCode {
+					t.Fatalf("expected problem %s, got %+v", tc.wantCode, status.Error)
+				}
+				return
+			}
+			result, _ := status.Result.(map[string]any)
+			if got, _ := json.Marshal(result["value"]); string(got) != mustJSON(tc.wantValue) || status.Progress != 1 {
+				t.Fatalf("expected value %s at progress 1, got %s at %v", mustJSON(tc.wantValue), got, status.Progress)
+			}
+		})
+	}
+}
+
+// TestJobErrors covers requests refused before a job is queued, and a job past its time limit.
+func TestJobErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{JobTimeout: time.Nanosecond})
+	content := sampleMatrixCSV
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, newMultipartRequest(t, "/jobs?op=nope", &content))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown op, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/0123456789abcdef", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown job, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, newMultipartRequest(t, "/jobs?op=rank", &content))
+	if status := pollJob(t, mux, rec.Header().Get("Location")); status.Error == nil || status.Error.Code != "timeout" {
+		t.Fatalf("expected a timeout problem, got %+v", status)
+	}
+}
+
+// TestJobCancel cancels a job waiting behind a busy worker, then removes it.
+func TestJobCancel(t *testing.T) {
+	q := jobs.NewQueue(1, 4)
+	defer q.Close()
+	mux := http.NewServeMux()
+	Register(mux, Config{Jobs: q})
+
+	// occupy the only worker so the submitted job stays queued
+	release := make(chan struct{})
+	defer close(release)
+	q.Submit("block", func(ctx context.Context, _ func(float64)) (any, error) {
+		<-release
+		return nil, nil
+	})
+
+	content := sampleMatrixCSV
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, newMultipartRequest(t, "/jobs?op=det", &content))
+	location := rec.Header().Get("Location")
+
+	steps := []struct {
+		method     string
+		wantCode   int
+		wantStatus jobs.Status
+	}{
+		{method: http.MethodDelete, wantCode: http.StatusOK, wantStatus: jobs.Canceled},
+		{method: http.MethodGet, wantCode: http.StatusOK, wantStatus: jobs.Canceled},
+		{method: http.MethodDelete, wantCode: http.StatusNoContent},
+		{method: http.MethodGet, wantCode: http.StatusNotFound},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(step.method, location, nil))
+		if rec.Code != step.wantCode {
+			t.Fatalf("%s: expected status %d, got %d %q", step.method, step.wantCode, rec.Code, rec.Body.String())
+		}
+		if step.wantStatus == "" {
+			continue
+		}
+		var status jobStatus
+		json.Unmarshal(rec.Body.Bytes(), &status)
+		if status.Status != step.wantStatus {
+			t.Fatalf("%s: expected %s, got %s", step.method, step.wantStatus, status.Status)
+		}
+	}
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
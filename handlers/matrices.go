@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"league_challenge/store"
+	"net/http"
+)
+
+/*
+	This file has the /matrices resource, storing uploads for reuse:
+		POST   /matrices        stores the upload, responds 201 with its ID
+		GET    /matrices/{id}   the stored matrix, as csv or JSON
+		DELETE /matrices/{id}   removes it
+	Any operation then takes ?id= in place of an upload.
+*/
+
+// Registers the /matrices routes over cfg.Store.
+func registerMatrices(mux *http.ServeMux, cfg Config) {
+	mux.Handle("POST /matrices", cfg.metrics.instrument("matrices", withConfig(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		createMatrix(w, r, cfg.Store)
+	}), cfg)))
+	mux.Handle("OPTIONS /matrices", options([]string{"file"}))
+	mux.HandleFunc("GET /matrices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		getMatrix(w, r, cfg.Store)
+	})
+	mux.HandleFunc("DELETE /matrices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deleteMatrix(w, r, cfg.Store)
+	})
+}
+
+// Stores the uploaded matrix, responding with its ID and Location.
+func createMatrix(w http.ResponseWriter, r *http.Request, s store.Store) {
+	contentType, err := negotiate(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	in, err := loadInputs(r, []string{"file"})
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	id, err := s.Put(in[0])
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	w.Header().Set("Location", "/matrices/"+id)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusCreated)
+	render(w, contentType, scalar(id), KindScalar)
+}
+
+// Writes the stored matrix in the negotiated content type.
+func getMatrix(w http.ResponseWriter, r *http.Request, s store.Store) {
+	contentType, err := negotiate(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	m, err := s.Get(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	render(w, contentType, m, KindMatrix)
+}
+
+// Removes the stored matrix.
+func deleteMatrix(w http.ResponseWriter, r *http.Request, s store.Store) {
+	if err := s.Delete(r.PathValue("id")); err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMatrices stores uploads, runs operations on them by ID, fetches and
+// deletes them, as a client would.
+func TestMatrices(t *testing.T) {
+	mux := newTestMux()
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	put := func(content string) string {
+		rec := serve(newMultipartRequest(t, "/matrices", &content))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		id := rec.Body.String()
+		if loc := rec.Header().Get("Location"); loc != "/matrices/"+id {
+			t.Fatalf("expected Location /matrices/%s, got %q", id, loc)
+		}
+		return id
+	}
+
+	id := put(sampleMatrixCSV)
+	a, b := put("1,2\n3,4\n"), put("5\n6\n")
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{name: "get", method: http.MethodGet, target: "/matrices/" + id, wantCode: http.StatusOK, wantBody: "1,2,3\n4,5,6\n7,8,9\n"},
+		{name: "operation", method: http.MethodPost, target: "/add?id=" + id, wantCode: http.StatusOK, wantBody: "45"},
+		{name: "not streamed", method: http.MethodPost, target: "/flatten?id=" + id, wantCode: http.StatusOK, wantBody: "1,2,3,4,5,6,7,8,9"},
+		{name: "pipeline", method: http.MethodPost, target: "/pipeline?ops=transpose,flatten&id=" + id, wantCode: http.StatusOK, wantBody: "1,4,7,2,5,8,3,6,9"},
+		{name: "two inputs", method: http.MethodPost, target: "/matmul?id=" + a + "&id=" + b, wantCode: http.StatusOK, wantBody: "17\n39\n"},
+		{name: "too few ids", method: http.MethodPost, target: "/matmul?id=" + a, wantCode: http.StatusBadRequest, wantBody: "expected one per input (a,b)"},
+		{name: "unknown id", method: http.MethodPost, target: "/det?id=0123456789abcdef", wantCode: http.StatusNotFound, wantBody: "no matrix with id '0123456789abcdef'"},
+		{name: "delete", method: http.MethodDelete, target: "/matrices/" + id, wantCode: http.StatusNoContent},
+		{name: "get deleted", method: http.MethodGet, target: "/matrices/" + id, wantCode: http.StatusNotFound, wantBody: `"code":"not-found"`},
+		{name: "delete deleted", method: http.MethodDelete, target: "/matrices/" + id, wantCode: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(httptest.NewRequest(tc.method, tc.target, nil))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, body)
+			}
+		})
+	}
+}
+
+// TestMatricesJSON checks matrices can be stored from, and fetched as, JSON.
+func TestMatricesJSON(t *testing.T) {
+	mux := newTestMux()
+	req := httptest.NewRequest(http.MethodPost, "/matrices", strings.NewReader(`{"matrix": [[1, 2], [3, 4]]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, rec.Header().Get("Location"), nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if want := `{"kind":"matrix","shape":[2,2],"value":[[1,2],[3,4]]}` + "\n"; rec.Body.String() != want {
+		t.Fatalf("expected %q, got %q", want, rec.Body.String())
+	}
+}
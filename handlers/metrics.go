@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"league_challenge/matrix"
+	"league_challenge/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+	This file has the service's metrics, served by GET /metrics:
+		matrix_requests_total{op,status}             requests answered per operation and status,
+		                                             status="aborted" for a stream failing partway
+		matrix_request_duration_seconds{op,status}   their latency
+		matrix_requests_in_flight{op}                requests being served
+		matrix_input_rows, matrix_input_cols         dimensions of every parsed upload
+		matrix_parse_errors_total{kind}              rejected uploads, eg: kind="non-numeric-cell"
+		matrix_cache_hits_total, ..._misses_total    the result cache's counters
+	op is the operation name (aliases count under it), or pipeline, matrices and jobs.
+*/
+
+// serverMetrics are the metrics Register records into Config.Metrics.
+type serverMetrics struct {
+	requests    *metrics.Counter
+	latency     *metrics.Histogram
+	inFlight    *metrics.Gauge
+	rows        *metrics.Histogram
+	cols        *metrics.Histogram
+	parseErrors *metrics.Counter
+}
+
+// Registers the service's metrics into reg, reading the cache's counters as they are written.
+func newServerMetrics(reg *metrics.Registry, c *ResultCache) *serverMetrics {
+	dims := metrics.ExponentialBuckets(1, 4, 8) // 1 to 16384
+	m := &serverMetrics{
+		requests:    reg.NewCounter("matrix_requests_total", "Requests answered, by operation and status.", "op", "status"),
+		latency:     reg.NewHistogram("matrix_request_duration_seconds", "Request latency, by operation and status.", metrics.ExponentialBuckets(0.001, 4, 8), "op", "status"),
+		inFlight:    reg.NewGauge("matrix_requests_in_flight", "Requests being served, by operation.", "op"),
+		rows:        reg.NewHistogram("matrix_input_rows", "Rows of every parsed upload.", dims),
+		cols:        reg.NewHistogram("matrix_input_cols", "Columns of every parsed upload.", dims),
+		parseErrors: reg.NewCounter("matrix_parse_errors_total", "Uploads rejected while parsing, by kind.", "kind"),
+	}
+	reg.NewCounterFunc("matrix_cache_hits_total", "Results served from the cache.", func() float64 { return float64(c.Stats().Hits) })
+	reg.NewCounterFunc("matrix_cache_misses_total", "Results computed for lack of a cached one.", func() float64 { return float64(c.Stats().Misses) })
+	reg.NewGaugeFunc("matrix_cache_cells", "Result cells held in the cache.", func() float64 { return float64(c.Stats().Size) })
+	return m
+}
+
+// Counts, times and tracks in flight every request h serves, under op.
+// A response aborted after its status was sent counts as status "aborted".
+func (m *serverMetrics) instrument(op string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Inc(op)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			status := strconv.Itoa(rec.status)
+			v := recover()
+			switch {
+			case v == http.ErrAbortHandler:
+				status = "aborted"
+			case v != nil:
+				// middleware.Recover answers any other panic with a 500
+				status = strconv.Itoa(http.StatusInternalServerError)
+			}
+			if v != nil {
+				defer panic(v)
+			}
+			m.inFlight.Dec(op)
+			m.requests.Inc(op, status)
+			m.latency.Observe(time.Since(start).Seconds(), op, status)
+		}()
+		h.ServeHTTP(rec, r)
+	})
+}
+
+// Records the dimensions of a parsed upload, a no-op outside Register.
+func observeSize(ctx context.Context, rows, cols int) {
+	if m := configFrom(ctx).metrics; m != nil {
+		m.rows.Observe(float64(rows))
+		m.cols.Observe(float64(cols))
+	}
+}
+
+// Counts err if it rejected an upload while parsing, a no-op outside Register.
+func observeParseError(ctx context.Context, err error) {
+	m := configFrom(ctx).metrics
+	if m == nil || err == nil {
+		return
+	}
+	if kind, ok := parseErrorKind(err); ok {
+		m.parseErrors.Inc(kind)
+	}
+}
+
+// Returns the kind of a parse error, false for errors found after parsing, eg: singular.
+func parseErrorKind(err error) (string, bool) {
+	var limitErr *matrix.LimitError
+	var bytesErr *http.MaxBytesError
+	var cellErr *matrix.NonNumericCellError
+	var csvErr *csv.ParseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var flateErr flate.CorruptInputError
+	switch {
+	case errors.As(err, &limitErr), errors.As(err, &bytesErr):
+		return "too-large", true
+	case errors.As(err, &cellErr):
+		return "non-numeric-cell", true
+	case errors.Is(err, matrix.ErrMissingCell):
+		return "missing-cell", true
+	case errors.Is(err, matrix.ErrEmptyMatrix):
+		return "empty-matrix", true
+	case errors.Is(err, csv.ErrFieldCount):
+		return "ragged-rows", true
+	case errors.As(err, &csvErr):
+		return "malformed-csv", true
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return "malformed-json", true
+	case errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum), errors.Is(err, zlib.ErrHeader),
+		errors.Is(err, zlib.ErrChecksum), errors.Is(err, zip.ErrFormat), errors.As(err, &flateErr):
+		return "malformed-compressed", true
+	}
+	return "", false
+}
+
+// statusRecorder captures the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, eg: to flush.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
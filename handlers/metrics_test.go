@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"league_challenge/matrix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMetrics serves a mix of requests, then checks /metrics counted each by
+// operation and status, the upload sizes and the parse errors by kind.
+func TestMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{Limits: matrix.Limits{MaxRows: 3}, StreamAbove: 1 << 20})
+
+	upload := func(target, content string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, newMultipartRequest(t, target, &content))
+	}
+	upload("/transpose", "1,2\n3,4\n")
+	upload("/transpose", "1,2\n3,4\n")
+	upload("/invert", "2\n")
+	upload("/add", "1,x\n")
+	upload("/add", "1,2\n3\n")
+	upload("/echo", "1\n2\n3\n4\n")
+	upload("/pipeline?ops=transpose", "1,2,3\n")
+	req := httptest.NewRequest(http.MethodPost, "/det", strings.NewReader(`{"matrix": [[1,}`))
+	req.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Fatalf("expected the text exposition format, got %q", got)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`matrix_requests_total{op="transpose",status="200"} 2`,
+		`matrix_requests_total{op="inverse",status="200"} 1`,
+		`matrix_requests_total{op="add",status="400"} 2`,
+		`matrix_requests_total{op="echo",status="413"} 1`,
+		`matrix_requests_total{op="pipeline",status="200"} 1`,
+		`matrix_requests_total{op="det",status="400"} 1`,
+		`matrix_request_duration_seconds_count{op="transpose",status="200"} 2`,
+		`matrix_requests_in_flight{op="transpose"} 0`,
+		`matrix_input_rows_count 5`,
+		`matrix_input_cols_bucket{le="1"} 1`,
+		`matrix_input_cols_bucket{le="4"} 5`,
+		`matrix_parse_errors_total{kind="non-numeric-cell"} 1`,
+		`matrix_parse_errors_total{kind="ragged-rows"} 1`,
+		`matrix_parse_errors_total{kind="too-large"} 1`,
+		`matrix_parse_errors_total{kind="malformed-json"} 1`,
+		`matrix_cache_hits_total 1`,
+		`matrix_cache_misses_total 3`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Fatalf("expected %q in:\n%s", want, body)
+		}
+	}
+}
+
+// TestParseErrorKind checks which errors count as parse errors.
+func TestParseErrorKind(t *testing.T) {
+	tests := []struct {
+		err    error
+		want   string
+		wantOK bool
+	}{
+		{err: &matrix.NonNumericCellError{Row: 1, Col: 1}, want: "non-numeric-cell", wantOK: true},
+		{err: fmt.Errorf("error: %w", &matrix.LimitError{Limit: "rows"}), want: "too-large", wantOK: true},
+		{err: &http.MaxBytesError{Limit: 1}, want: "too-large", wantOK: true},
+		{err: matrix.ErrEmptyMatrix, want: "empty-matrix", wantOK: true},
+		{err: &matrix.MissingCellError{Row: 1, Col: 1}, want: "missing-cell", wantOK: true},
+		{err: matrix.ErrSingular, wantOK: false},
+		{err: errors.New("error: unknown precision"), wantOK: false},
+	}
+	for _, tc := range tests {
+		if got, ok := parseErrorKind(tc.err); got != tc.want || ok != tc.wantOK {
+			t.Fatalf("parseErrorKind(%v) = %q, %v, expected %q, %v", tc.err, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
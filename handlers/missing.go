@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"league_challenge/matrix"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+/*
+	This file applies the ?missing= policy for empty cells, see matrix.FillMissing:
+		/add?missing=skip        empty cells are left out of the sum
+		/det?missing=row-mean    empty cells are the mean of the rest of their row
+		/echo?missing=skip       empty cells are written as NA
+	Without ?missing= empty cells are left as they are, and numeric operations
+	reject them as non-numeric. The number of empty cells is reported in the
+	X-Missing-Cells header whenever a policy is given.
+*/
+
+// missingHeader is the response header counting the empty cells of the inputs.
+const missingHeader = "X-Missing-Cells"
+
+// Returns in with its empty cells filled per ?missing=, as op parses them:
+// integer operations take float means, or exact fractions with ?precision=big, so
+// a mean that is not a whole number promotes them (see integers). Text operations
+// write skipped cells as NA.
+// Reports how many cells were empty.
+func (op Operation) fillMissing(in []*matrix.Matrix[string], query url.Values) ([]*matrix.Matrix[string], int, error) {
+	policy, err := matrix.ParseMissing(query.Get("missing"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fill, skip := matrix.FillMissing[string], "NA"
+	switch op.Element {
+	case Integer:
+		fill, skip = matrix.FillMissing[float64], op.Skip
+		if query.Get("precision") == "big" {
+			fill = matrix.FillMissing[*big.Rat]
+		}
+	case Rational:
+		fill, skip = matrix.FillMissing[*big.Rat], op.Skip
+	}
+
+	filled := make([]*matrix.Matrix[string], len(in))
+	total := 0
+	for i, m := range in {
+		var count int
+		if filled[i], count, err = fill(m, policy, skip); err != nil {
+			return nil, 0, err
+		}
+		total += count
+	}
+	return filled, total, nil
+}
+
+// Sets the X-Missing-Cells header to count if the request gave a ?missing= policy.
+func reportMissing(w http.ResponseWriter, r *http.Request, count int) {
+	if r.URL.Query().Has("missing") {
+		w.Header().Set(missingHeader, strconv.Itoa(count))
+	}
+}
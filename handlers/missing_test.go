@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMissingPolicy uploads a matrix with empty cells under each ?missing=
+// policy, checking the result, the problem for cells a policy cannot fill and
+// the X-Missing-Cells count.
+func TestMissingPolicy(t *testing.T) {
+	mux := newTestMux()
+	const content = "1,,3\n4,5,\n"
+
+	tests := []struct {
+		name      string
+		target    string
+		content   string
+		stream    bool
+		wantCode  int
+		wantBody  string
+		wantCount string
+	}{
+		{name: "no policy", target: "/add", content: content, wantCode: http.StatusBadRequest, wantBody: `"code":"non-numeric-cell"`},
+		{name: "no policy streamed", target: "/add", content: content, stream: true, wantCode: http.StatusBadRequest, wantBody: `"code":"non-numeric-cell"`},
+		{name: "reject", target: "/add?missing=reject", content: content, wantCode: http.StatusBadRequest, wantBody: `"code":"missing-cell"`},
+		{name: "reject complete", target: "/add?missing=reject", content: "1,2\n", wantCode: http.StatusOK, wantBody: "3", wantCount: "0"},
+		{name: "zero", target: "/mul?missing=zero", content: content, wantCode: http.StatusOK, wantBody: "0", wantCount: "2"},
+		{name: "skip sum", target: "/add?missing=skip", content: content, wantCode: http.StatusOK, wantBody: "13", wantCount: "2"},
+		{name: "skip product", target: "/mul?missing=na", content: content, stream: true, wantCode: http.StatusOK, wantBody: "60", wantCount: "2"},
+		{name: "skip text", target: "/transpose?missing=skip", content: content, wantCode: http.StatusOK, wantBody: "1,4\nNA,5\n3,NA\n", wantCount: "2"},
+		{name: "skip unsupported", target: "/matmul?missing=skip", wantCode: http.StatusBadRequest, wantBody: "cannot be skipped by this operation"},
+		{name: "row mean", target: "/add?missing=row-mean", content: content, wantCode: http.StatusOK, wantBody: "19.5", wantCount: "2"},
+		{name: "row mean exact", target: "/add?missing=row-mean&precision=big", content: "1,2,\n3,4,5\n", wantCode: http.StatusOK, wantBody: "33/2", wantCount: "1"},
+		{name: "whole row mean stays integer", target: "/add?missing=row-mean", content: "1,3,\n", wantCode: http.StatusOK, wantBody: "6", wantCount: "1"},
+		{name: "col mean", target: "/det?missing=col-mean", content: "1,\n3,4\n", wantCode: http.StatusOK, wantBody: "-8", wantCount: "1"},
+		{name: "empty row", target: "/add?missing=row-mean", content: "1,2\n,\n", wantCode: http.StatusBadRequest, wantBody: "row 2 has no values to take the mean of"},
+		{name: "pipeline", target: "/pipeline?ops=transpose,add&missing=skip", content: content, wantCode: http.StatusOK, wantBody: "13", wantCount: "2"},
+		{name: "unknown policy", target: "/add?missing=mode", content: content, wantCode: http.StatusBadRequest, wantBody: "unknown missing value policy 'mode'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var req *http.Request
+			if tc.content == "" {
+				req = newMultipartFilesRequest(t, tc.target, map[string]string{"a": "1,\n", "b": "1\n2\n"})
+			} else {
+				req = newMultipartRequest(t, tc.target, &tc.content)
+			}
+			if tc.stream {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, rec.Body.String())
+			}
+			if got := rec.Header().Get(missingHeader); got != tc.wantCount {
+				t.Fatalf("expected %s %q, got %q", missingHeader, tc.wantCount, got)
+			}
+		})
+	}
+}
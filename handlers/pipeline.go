@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"league_challenge/matrix"
+	"net/http"
+	"strings"
+)
+
+/*
+	This file has the /pipeline endpoint, chaining registered operations.
+	The uploaded matrix is parsed once, as strings, and handed from step to step.
+	Numeric steps parse the cells into the type they need and hand back strings.
+*/
+
+// Runs an ordered list of operations, eg: ?ops=transpose,rotate90,flatten
+// The matrix is uploaded once and each step's output feeds the next.
+func Pipeline(w http.ResponseWriter, r *http.Request) {
+
+	// pick the response format and resolve every step before reading the upload
+	contentType, err := negotiate(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	names, steps, err := parseOps(r.URL.Query().Get("ops"))
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	in, err := loadInputs(r, []string{"file"})
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	in, missing, err := fillStep(steps).fillMissing(in, r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	reportMissing(w, r, missing)
+	m := in[0]
+
+	// run each step on the previous step's output
+	for i, step := range steps {
+		in := []*matrix.Matrix[string]{m}
+		if m, err = step.apply(r.Context(), in, r.URL.Query()); err != nil {
+			p := problemFor(err)
+			p.Step = i + 1
+			p.Detail = fmt.Sprintf("error: pipeline step %d (%s) failed. %s", i+1, names[i], p.Detail)
+			writeProblem(w, r, p)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	render(w, contentType, m, steps[len(steps)-1].Result)
+}
+
+// Returns the step whose element type fills the upload's empty cells, the first
+// numeric step, or the first step if every step works on text.
+func fillStep(steps []Operation) Operation {
+	for _, step := range steps {
+		if step.Element != Text {
+			return step
+		}
+	}
+	return steps[0]
+}
+
+// Splits a comma separated ops list and looks up each step in the registry.
+// Returns error naming the first unknown step, or a step that takes more than one matrix.
+func parseOps(ops string) ([]string, []Operation, error) {
+	if strings.TrimSpace(ops) == "" {
+		return nil, nil, fmt.Errorf("error: no operations given. pass ?ops= with one or more of %s", strings.Join(operationNames(), ","))
+	}
+
+	names := strings.Split(ops, ",")
+	steps := make([]Operation, len(names))
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		step, ok := lookup(names[i])
+		if !ok {
+			return nil, nil, fmt.Errorf("error: pipeline step %d has unknown operation '%s'. must be one of %s", i+1, names[i], strings.Join(operationNames(), ","))
+		}
+		if n := len(step.inputs()); n > 1 {
+			return nil, nil, fmt.Errorf("error: pipeline step %d operation '%s' takes %d matrices and cannot be chained", i+1, names[i], n)
+		}
+		steps[i] = step
+	}
+	return names, steps, nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestPipeline runs chains of operations against the sample matrix, covering
+// matrix, vector and scalar final results and failures at a specific step.
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		ops      string
+		input    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "transpose then flatten",
+			ops:      "transpose,flatten",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusOK,
+			wantBody: "1,4,7,2,5,8,3,6,9",
+		},
+		{
+			name:     "transpose rotate flatten",
+			ops:      "transpose,rotate90,flatten",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusOK,
+			wantBody: "3,2,1,6,5,4,9,8,7",
+		},
+		{
+			name:     "matrix result",
+			ops:      "rotate90, rotate90",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusOK,
+			wantBody: "9,8,7\n6,5,4\n3,2,1\n",
+		},
+		{
+			name:     "inverse then determinant",
+			ops:      "inverse,det",
+			input:    "2,1\n4,3\n",
+			wantCode: http.StatusOK,
+			wantBody: "1/2",
+		},
+		{
+			name:     "scalar feeds next step",
+			ops:      "add,mul",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusOK,
+			wantBody: "45",
+		},
+		{
+			name:     "fails at step",
+			ops:      "transpose,inverse",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "pipeline step 2 (inverse) failed",
+		},
+		{
+			name:     "non-int after inverse",
+			ops:      "inverse,add",
+			input:    "2,1\n4,3\n",
+			wantCode: http.StatusBadRequest,
+			wantBody: "pipeline step 2 (add) failed. error: non-int values",
+		},
+		{
+			name:     "unknown operation",
+			ops:      "transpose,spin",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusBadRequest,
+			wantBody: "pipeline step 2 has unknown operation 'spin'",
+		},
+		{
+			name:     "multi-input operation",
+			ops:      "transpose,matmul",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusBadRequest,
+			wantBody: "pipeline step 2 operation 'matmul' takes 2 matrices",
+		},
+		{
+			name:     "no operations",
+			ops:      "",
+			input:    sampleMatrixCSV,
+			wantCode: http.StatusBadRequest,
+			wantBody: "no operations given",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, "/pipeline?ops="+strings.ReplaceAll(tc.ops, " ", "+"), &tc.input)
+			rec := httptest.NewRecorder()
+
+			newTestMux().ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+
+			if body := rec.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, body)
+			}
+		})
+	}
+}
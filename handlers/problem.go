@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"league_challenge/jobs"
+	"league_challenge/matrix"
+	"league_challenge/middleware"
+	"league_challenge/store"
+	"net/http"
+)
+
+/*
+	This file has error responses, written as RFC 7807 problem details:
+		{"type": "urn:matrix:problem:non-numeric-cell", "title": "Non-numeric cell",
+		 "status": 400, "detail": "error: non-int values in matrix. row 2, col 3 has \"six\"",
+		 "instance": "/add", "code": "non-numeric-cell", "row": 2, "col": 3, "value": "six"}
+	code is stable for clients to switch on, the extension members (row, col, value,
+	rows, cols, step, limit) locate the problem where there is something to locate.
+*/
+
+// problemType prefixes the code to form the problem type URI.
+const problemType = "urn:matrix:problem:"
+
+// errNotAcceptable is returned by negotiate when no response type is acceptable.
+var errNotAcceptable = errors.New("error: not acceptable")
+
+// problem is an RFC 7807 problem details body with this service's extension members.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+
+	RequestID string `json:"request_id,omitempty"` // matches the X-Request-ID header
+
+	Step  int     `json:"step,omitempty"`  // 1-based pipeline step that failed
+	Row   int     `json:"row,omitempty"`   // 1-based row of the offending cell
+	Col   int     `json:"col,omitempty"`   // 1-based column of the offending cell
+	Value *string `json:"value,omitempty"` // offending cell, may be empty
+	Rows  int     `json:"rows,omitempty"`  // shape of the offending matrix
+	Cols  int     `json:"cols,omitempty"`
+	Limit int64   `json:"limit,omitempty"` // the limit an oversized upload broke
+
+	err error // the error described, counted by writeProblem if it is a parse error
+}
+
+// Returns the problem describing err, with the status and code its kind maps to.
+// Well-formed input with no answer (singular, overflowing) is 422, unknown errors are 400.
+// Work cut short by the route's deadline is 504, by the client going away 503.
+// Uploads over a byte, row, column or cell limit, compressed or not, are 413.
+func problemFor(err error) *problem {
+	p := &problem{Status: http.StatusBadRequest, Code: "invalid-request", Title: "Invalid request", Detail: err.Error(), err: err}
+
+	var cellErr *matrix.NonNumericCellError
+	var shapeErr *matrix.NotSquareError
+	var limitErr *matrix.LimitError
+	var missingErr *matrix.MissingCellError
+	var bytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &bytesErr):
+		p.Status, p.Code, p.Title = http.StatusRequestEntityTooLarge, "too-large", "Upload too large"
+		p.Detail = fmt.Sprintf("error: upload too large. request body is over the limit of %d bytes", bytesErr.Limit)
+		p.Limit = bytesErr.Limit
+	case errors.As(err, &limitErr):
+		p.Status, p.Code, p.Title = http.StatusRequestEntityTooLarge, "too-large", "Upload too large"
+		p.Row, p.Col, p.Limit = limitErr.Row, limitErr.Col, int64(limitErr.Max)
+	case errors.As(err, &cellErr):
+		p.Code, p.Title = "non-numeric-cell", "Non-numeric cell"
+		p.Row, p.Col, p.Value = cellErr.Row, cellErr.Col, &cellErr.Value
+	case errors.As(err, &missingErr):
+		p.Code, p.Title = "missing-cell", "Missing cell"
+		p.Row, p.Col = missingErr.Row, missingErr.Col
+	case errors.As(err, &shapeErr):
+		p.Code, p.Title = "not-square", "Matrix is not square"
+		p.Rows, p.Cols = shapeErr.Rows, shapeErr.Cols
+	case errors.Is(err, matrix.ErrEmptyMatrix):
+		p.Code, p.Title = "empty-matrix", "Empty matrix"
+	case errors.Is(err, matrix.ErrOverflow):
+		p.Status, p.Code, p.Title = http.StatusUnprocessableEntity, "overflow", "Overflow"
+		p.Detail += ". use precision=big for an exact result"
+	case errors.Is(err, matrix.ErrSingular):
+		p.Status, p.Code, p.Title = http.StatusUnprocessableEntity, "singular", "Matrix is singular"
+	case errors.Is(err, context.DeadlineExceeded):
+		p.Status, p.Code, p.Title = http.StatusGatewayTimeout, "timeout", "Operation timed out"
+		p.Detail = "error: operation did not finish within the time limit. try a smaller matrix"
+	case errors.Is(err, context.Canceled):
+		p.Status, p.Code, p.Title = http.StatusServiceUnavailable, "canceled", "Operation canceled"
+		p.Detail = "error: operation canceled before it finished"
+	case errors.Is(err, store.ErrNotFound):
+		p.Status, p.Code, p.Title = http.StatusNotFound, "not-found", "Matrix not found"
+	case errors.Is(err, jobs.ErrNotFound):
+		p.Status, p.Code, p.Title = http.StatusNotFound, "not-found", "Job not found"
+	case errors.Is(err, jobs.ErrQueueFull), errors.Is(err, jobs.ErrClosed):
+		p.Status, p.Code, p.Title = http.StatusServiceUnavailable, "queue-full", "Job queue full"
+	case errors.Is(err, matrix.ErrUnsupportedEncoding):
+		p.Status, p.Code, p.Title = http.StatusUnsupportedMediaType, "unsupported-encoding", "Unsupported content encoding"
+	case errors.Is(err, errNotAcceptable):
+		p.Status, p.Code, p.Title = http.StatusNotAcceptable, "not-acceptable", "Not acceptable"
+	}
+	p.Type = problemType + p.Code
+	return p
+}
+
+// Writes p as application/problem+json, for the request's path and ID.
+func writeProblem(w http.ResponseWriter, r *http.Request, p *problem) {
+	observeParseError(r.Context(), p.err)
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.RequestIDFrom(r.Context())
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
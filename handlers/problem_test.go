@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestProblemResponses checks each error kind is written as problem+json with
+// its status, code and the extension members locating the problem.
+func TestProblemResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		input  string
+		accept string
+		want   map[string]any
+	}{
+		{
+			name:   "non-numeric cell",
+			target: "/add",
+			input:  "1,2,3\n4,5,six\n",
+			want:   map[string]any{"status": 400.0, "code": "non-numeric-cell", "row": 2.0, "col": 3.0, "value": "six"},
+		},
+		{
+			name:   "empty cell",
+			target: "/add",
+			input:  "1,\n3,4\n",
+			want:   map[string]any{"code": "non-numeric-cell", "row": 1.0, "col": 2.0, "value": ""},
+		},
+		{
+			name:   "rejected empty cell",
+			target: "/add?missing=reject",
+			input:  "1,\n3,4\n",
+			want:   map[string]any{"status": 400.0, "code": "missing-cell", "row": 1.0, "col": 2.0},
+		},
+		{
+			name:   "not square",
+			target: "/det",
+			input:  "1,2,3\n4,5,6\n",
+			want:   map[string]any{"status": 400.0, "code": "not-square", "rows": 2.0, "cols": 3.0},
+		},
+		{
+			name:   "empty matrix",
+			target: "/echo",
+			input:  "",
+			want:   map[string]any{"status": 400.0, "code": "empty-matrix", "type": "urn:matrix:problem:empty-matrix"},
+		},
+		{
+			name:   "overflow",
+			target: "/mul",
+			input:  "9223372036854775807,2\n1,1\n",
+			want:   map[string]any{"status": 422.0, "code": "overflow"},
+		},
+		{
+			name:   "singular",
+			target: "/inverse",
+			input:  sampleMatrixCSV,
+			want:   map[string]any{"status": 422.0, "code": "singular", "instance": "/inverse"},
+		},
+		{
+			name:   "pipeline step",
+			target: "/pipeline?ops=transpose,inverse,add",
+			input:  "2,1\n4,3\n",
+			want:   map[string]any{"code": "non-numeric-cell", "step": 3.0, "row": 1.0, "col": 1.0, "value": "3/2"},
+		},
+		{
+			name:   "not acceptable",
+			target: "/echo",
+			input:  sampleMatrixCSV,
+			accept: "image/png",
+			want:   map[string]any{"status": 406.0, "code": "not-acceptable"},
+		},
+		{
+			name:   "other errors",
+			target: "/add?precision=huge",
+			input:  sampleMatrixCSV,
+			want:   map[string]any{"status": 400.0, "code": "invalid-request", "title": "Invalid request"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, tc.target, &tc.input)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			newTestMux().ServeHTTP(rec, req)
+
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("expected problem+json, got %q", ct)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid problem body %q: %v", rec.Body.String(), err)
+			}
+			if status, _ := body["status"].(float64); int(status) != rec.Code {
+				t.Fatalf("body status %v does not match response status %d", body["status"], rec.Code)
+			}
+			if detail, _ := body["detail"].(string); !strings.HasPrefix(detail, "error: ") {
+				t.Fatalf("expected detail message, got %q", detail)
+			}
+			for key, want := range tc.want {
+				if body[key] != want {
+					t.Fatalf("expected %s=%v, got %v in %s", key, want, body[key], rec.Body.String())
+				}
+			}
+		})
+	}
+}
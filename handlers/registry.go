@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"league_challenge/cache"
+	"league_challenge/jobs"
+	"league_challenge/matrix"
+	"league_challenge/metrics"
+	"league_challenge/store"
+	"math/big"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*
+	This file has the operation registry.
+	An Operation is declared once, in operations, and from it the registry
+	generates the HTTP route, the /pipeline step and the /help entry. Upload,
+	validation, error responses and content negotiation all live here so an
+	operation only has to describe the work itself.
+*/
+
+// Shape is the input shape an operation requires.
+type Shape int
+
+const (
+	AnyShape Shape = iota
+	Square
+)
+
+func (s Shape) String() string {
+	if s == Square {
+		return "NxN"
+	}
+	return "MxN"
+}
+
+// Element is the cell type an operation parses its input into.
+type Element int
+
+const (
+	Text     Element = iota // any csv cell
+	Integer                 // int64, or big.Int with ?precision=big. promoted to float64, or big.Rat, by a decimal cell
+	Rational                // exact fractions, accepts ints, decimals and "a/b"
+)
+
+func (e Element) String() string {
+	switch e {
+	case Integer:
+		return "int/float"
+	case Rational:
+		return "rational"
+	}
+	return "text"
+}
+
+// Kind is the shape of an operation's result, deciding how it is written out.
+type Kind int
+
+const (
+	KindMatrix Kind = iota
+	KindVector
+	KindScalar
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindVector:
+		return "vector"
+	case KindScalar:
+		return "scalar"
+	}
+	return "matrix"
+}
+
+// RunFunc does an operation's work on its uploaded matrices, in Inputs order.
+// Vectors and scalars are returned as 1xN and 1x1 matrices so results can be chained.
+// Long running work should stop and return ctx.Err() once ctx is done.
+type RunFunc func(ctx context.Context, in []*matrix.Matrix[string], query url.Values) (*matrix.Matrix[string], error)
+
+// StreamFunc does a single input operation's work row by row as the upload is
+// read, writing its csv result to w, so the matrix is never held whole.
+type StreamFunc func(ctx context.Context, s *matrix.Scanner, w io.Writer, query url.Values) error
+
+// Operation is a matrix operation exposed over HTTP.
+// Element documents the cell type Run parses into, use the adapter of the same name (as, integers).
+type Operation struct {
+	Name    string   // route is /Name, also the /pipeline step name
+	Aliases []string // extra names for the same operation
+	Summary string
+	Inputs  []string // form file keys of the uploaded matrices, defaults to "file"
+	Shape   Shape
+	Element Element
+	Result  Kind
+	Skip    string // the cell a missing value stands for with ?missing=skip, eg: "0" in a sum, "" if it cannot be skipped
+	Run     RunFunc
+	Stream  StreamFunc // optional, used over Run for a csv upload answered in csv
+}
+
+// Config tunes the registered routes.
+type Config struct {
+	Timeout  time.Duration            // time limit for every route, 0 means none
+	Timeouts map[string]time.Duration // per route limits by name, eg: "det" or "pipeline"
+	MaxBytes int64                    // request body limit for uploads, 0 means none
+	Limits   matrix.Limits            // rows, cols and cell length of each uploaded matrix, MaxDecompressed defaults to MaxBytes
+	Store    store.Store              // matrices referenced by ?id=, in memory if nil
+
+	Jobs       *jobs.Queue   // runs /jobs, GOMAXPROCS workers with 64 waiting if nil
+	JobTimeout time.Duration // time limit for each job, 0 means none
+
+	Cache       *ResultCache // operation results, 1<<20 cells if nil
+	StreamAbove int64        // uploads of at most this many bytes are cached, larger or unsized ones stream
+
+	Metrics *metrics.Registry // served by /metrics, a new registry if nil
+	metrics *serverMetrics    // recorded into Metrics, set by Register
+}
+
+type ctxKey int
+
+const configKey ctxKey = iota
+
+// Returns the time limit for the route called name, an alias falls back to its operation's limit.
+func (c Config) timeout(name string) time.Duration {
+	if d, ok := c.Timeouts[name]; ok {
+		return d
+	}
+	if op, ok := lookup(name); ok {
+		if d, ok := c.Timeouts[op.Name]; ok {
+			return d
+		}
+	}
+	return c.Timeout
+}
+
+// Registers a route for every name of every operation, plus /pipeline, /matrices, /jobs, /cache, /metrics and /help.
+// Uploads are POST only, the mux answers other methods with 405 and an Allow header.
+// OPTIONS describes what each upload route accepts.
+func Register(mux *http.ServeMux, cfg Config) {
+	if cfg.Store == nil {
+		cfg.Store = store.NewMemory()
+	}
+	if cfg.Limits.MaxDecompressed == 0 {
+		cfg.Limits.MaxDecompressed = cfg.MaxBytes
+	}
+	if cfg.Jobs == nil {
+		cfg.Jobs = jobs.NewQueue(runtime.GOMAXPROCS(0), 64)
+	}
+	if cfg.Cache == nil {
+		cfg.Cache = cache.NewLRU[string, *matrix.Matrix[string]](defaultCacheCells)
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = metrics.NewRegistry()
+	}
+	cfg.metrics = newServerMetrics(cfg.Metrics, cfg.Cache)
+	for _, op := range operations {
+		for _, name := range op.names() {
+			mux.Handle("POST /"+name, cfg.metrics.instrument(op.Name, withConfig(withTimeout(op, cfg.timeout(name)), cfg)))
+			mux.Handle("OPTIONS /"+name, options(op.inputs()))
+		}
+	}
+	mux.Handle("POST /pipeline", cfg.metrics.instrument("pipeline", withConfig(withTimeout(http.HandlerFunc(Pipeline), cfg.timeout("pipeline")), cfg)))
+	mux.Handle("OPTIONS /pipeline", options([]string{"file"}))
+	registerMatrices(mux, cfg)
+	registerJobs(mux, cfg)
+	mux.Handle("GET /cache", cacheStats(cfg.Cache))
+	mux.Handle("GET /metrics", cfg.Metrics.Handler())
+	mux.HandleFunc("GET /help", Help)
+}
+
+// Serves a single operation: negotiates the response type, loads the uploads,
+// answers a matching If-None-Match with 304, otherwise runs the operation or
+// takes its cached result and writes it with an ETag.
+func (op Operation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// pick the response format before doing any work
+	contentType, err := negotiate(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	if op.streams(r, contentType) {
+		op.serveStream(w, r)
+		return
+	}
+
+	in, missing, err := op.load(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	reportMissing(w, r, missing)
+
+	// a client holding the current ETag needs neither the result nor the work
+	key := resultKey(op, in, r.URL.Query())
+	etag := etagFor(key, contentType)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Vary", "Accept")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	result, status, err := op.cached(r, key, in)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept")
+	if status != "" {
+		w.Header().Set("X-Cache", status)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	render(w, contentType, result, op.Result)
+}
+
+// Lists every operation with its route, inputs and requirements.
+func Help(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "route\tinputs\tshape\telement\tresult\tsummary")
+	for _, op := range operations {
+		routes := make([]string, 0, len(op.names()))
+		for _, name := range op.names() {
+			routes = append(routes, "/"+name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", strings.Join(routes, ","), strings.Join(op.inputs(), ","), op.Shape, op.Element, op.Result, op.Summary)
+	}
+	fmt.Fprintf(tw, "/pipeline\tfile\t-\t-\t-\tchains single-input operations, eg: ?ops=transpose,flatten\n")
+	fmt.Fprintf(tw, "/matrices\tfile\t-\t-\t-\tstores the upload for ?id=, GET or DELETE /matrices/{id}\n")
+	fmt.Fprintf(tw, "/jobs\t-\t-\t-\t-\truns ?op= in the background, poll GET /jobs/{id}, cancel with DELETE\n")
+	fmt.Fprintf(tw, "/cache\t-\t-\t-\t-\tGET the result cache's hits, misses and size\n")
+	fmt.Fprintf(tw, "/metrics\t-\t-\t-\t-\tGET request, upload and cache metrics in Prometheus text format\n")
+	tw.Flush()
+}
+
+// Sets a deadline of d on the request context, operations stop once it passes.
+// A zero d leaves h unlimited.
+func withTimeout(h http.Handler, d time.Duration) http.Handler {
+	if d <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Bounds the request body to cfg.MaxBytes, decodes its Content-Encoding and
+// hands cfg to loadInputs. A body declaring a larger Content-Length is rejected
+// before any of it is read, a decoded one is bounded by cfg.Limits.MaxDecompressed.
+func withConfig(h http.Handler, cfg Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), configKey, cfg)
+		if cfg.MaxBytes > 0 {
+			if r.ContentLength > cfg.MaxBytes {
+				writeProblem(w, r, problemFor(&http.MaxBytesError{Limit: cfg.MaxBytes}))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBytes)
+		}
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+			body, err := matrix.DecodeContent(r.Body, encoding, cfg.Limits)
+			if err != nil {
+				writeProblem(w, r.WithContext(ctx), problemFor(err))
+				return
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{body, r.Body}
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1 // the decoded length is unknown
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Returns the Config set by withConfig, the zero Config outside of it.
+func configFrom(ctx context.Context) Config {
+	cfg, _ := ctx.Value(configKey).(Config)
+	return cfg
+}
+
+// Describes an upload route: the Allow header, the request types in Accept-Post,
+// and a plain text summary of the inputs and response types.
+func options(inputs []string) http.HandlerFunc {
+	jsonKeys := make([]string, len(inputs))
+	for i, key := range inputs {
+		jsonKeys[i] = jsonKey(key)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "OPTIONS, POST")
+		w.Header().Set("Accept-Post", strings.Join(requestTypes, ", "))
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "POST %s\n", r.URL.Path)
+		fmt.Fprintf(w, "multipart/form-data: csv files with keys %s\n", strings.Join(inputs, ","))
+		fmt.Fprintf(w, "application/json: arrays of rows with keys %s\n", strings.Join(jsonKeys, ","))
+		fmt.Fprintf(w, "responds with: %s\n", strings.Join(responseTypes, ", "))
+	}
+}
+
+// Returns the operation registered under name or one of its aliases.
+func lookup(name string) (Operation, bool) {
+	for _, op := range operations {
+		for _, n := range op.names() {
+			if n == name {
+				return op, true
+			}
+		}
+	}
+	return Operation{}, false
+}
+
+// Returns every registered operation name, sorted, for error messages.
+func operationNames() []string {
+	var names []string
+	for _, op := range operations {
+		names = append(names, op.names()...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (op Operation) names() []string {
+	return append([]string{op.Name}, op.Aliases...)
+}
+
+func (op Operation) inputs() []string {
+	if len(op.Inputs) == 0 {
+		return []string{"file"}
+	}
+	return op.Inputs
+}
+
+// Loads every matrix the operation takes, as strings, with their empty cells
+// filled per ?missing=. Returns how many cells were empty.
+func (op Operation) load(r *http.Request) ([]*matrix.Matrix[string], int, error) {
+	in, err := loadInputs(r, op.inputs())
+	if err != nil {
+		return nil, 0, err
+	}
+	return op.fillMissing(in, r.URL.Query())
+}
+
+// Checks the declared shape requirement, then runs the operation.
+func (op Operation) apply(ctx context.Context, in []*matrix.Matrix[string], query url.Values) (*matrix.Matrix[string], error) {
+	if op.Shape == Square {
+		for _, m := range in {
+			if err := m.RequireSquare(op.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return op.Run(ctx, in, query)
+}
+
+// Picks the response content type from the Accept header, honouring q-values.
+// A missing Accept header gets the first of responseTypes.
+func negotiate(r *http.Request) (string, error) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return responseTypes[0], nil
+	}
+
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, _ := strings.Cut(part, ";")
+		mr := mediaRange{typ: strings.ToLower(strings.TrimSpace(typ)), q: 1}
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && k == "q" {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, mr := range ranges {
+		for _, typ := range responseTypes {
+			major, _, _ := strings.Cut(typ, "/")
+			if mr.typ == typ || mr.typ == "*/*" || mr.typ == major+"/*" {
+				return typ, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w. cannot respond with '%s'. supported types are %s", errNotAcceptable, accept, strings.Join(responseTypes, ","))
+}
+
+// typed is an operation body over matrices already parsed into T.
+type typed[T matrix.Element] func(ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error)
+
+// Adapts a body taking a single matrix.
+func unary[T matrix.Element](fn func(ctx context.Context, m *matrix.Matrix[T]) (*matrix.Matrix[string], error)) typed[T] {
+	return func(ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error) {
+		return fn(ctx, in[0])
+	}
+}
+
+// Adapts a reduction over a single matrix into a 1x1 result.
+// Takes the reduction's Context variant, eg: (*matrix.Matrix[int64]).AddContext
+func reduction[T matrix.Number](fn func(m *matrix.Matrix[T], ctx context.Context) (T, error)) typed[T] {
+	return func(ctx context.Context, in []*matrix.Matrix[T]) (*matrix.Matrix[string], error) {
+		v, err := fn(in[0], ctx)
+		if err != nil {
+			return nil, err
+		}
+		return scalar(matrix.Format(v)), nil
+	}
+}
+
+// Adapts a parallel reduction to run on GOMAXPROCS workers, eg: (*matrix.Matrix[int64]).AddParallel
+func allCPUs[T matrix.Number](fn func(m *matrix.Matrix[T], ctx context.Context, workers int) (T, error)) func(*matrix.Matrix[T], context.Context) (T, error) {
+	return func(m *matrix.Matrix[T], ctx context.Context) (T, error) {
+		return fn(m, ctx, 0)
+	}
+}
+
+// Adapts a reduction too cheap to need a context, eg: Trace.
+func quick[T matrix.Number](fn func(m *matrix.Matrix[T]) (T, error)) func(*matrix.Matrix[T], context.Context) (T, error) {
+	return func(m *matrix.Matrix[T], _ context.Context) (T, error) {
+		return fn(m)
+	}
+}
+
+// Runs body over the inputs parsed as T.
+func as[T matrix.Element](body typed[T]) RunFunc {
+	return func(ctx context.Context, in []*matrix.Matrix[string], _ url.Values) (*matrix.Matrix[string], error) {
+		parsed, err := parseAll[T](in)
+		if err != nil {
+			return nil, err
+		}
+		return body(ctx, parsed)
+	}
+}
+
+// Runs small over int64 cells by default, or exact over big.Int cells with ?precision=big.
+// A cell that is a number but not an integer, eg: 1.5 or 2e3, promotes the inputs
+// to float64 cells run by float, or with ?precision=big to exact big.Rat cells
+// run by fraction, which also takes fractions, eg: 1/3. Cells are parsed once,
+// again only if promoted.
+func integers(small typed[int64], exact typed[*big.Int], float typed[float64], fraction typed[*big.Rat]) RunFunc {
+	return func(ctx context.Context, in []*matrix.Matrix[string], query url.Values) (*matrix.Matrix[string], error) {
+		switch precision := query.Get("precision"); precision {
+		case "", "int":
+			ints, floats, err := matrix.ParsePromoted[int64, float64](in...)
+			if err != nil {
+				return nil, err
+			}
+			if floats != nil {
+				return float(ctx, floats)
+			}
+			return small(ctx, ints)
+		case "big":
+			ints, fractions, err := matrix.ParsePromoted[*big.Int, *big.Rat](in...)
+			if err != nil {
+				return nil, err
+			}
+			if fractions != nil {
+				return fraction(ctx, fractions)
+			}
+			return exact(ctx, ints)
+		default:
+			return nil, fmt.Errorf("error: unknown precision '%s'. must be one of 'int' or 'big'", precision)
+		}
+	}
+}
+
+// maxPlaces bounds ?decimals= and ?digits=.
+const maxPlaces = 100
+
+// Runs run, then writes its numeric cells per ?decimals= or ?digits=, see numberFormat.
+func formatted(run RunFunc) RunFunc {
+	return func(ctx context.Context, in []*matrix.Matrix[string], query url.Values) (*matrix.Matrix[string], error) {
+		format, err := numberFormat(query)
+		if err != nil {
+			return nil, err
+		}
+		result, err := run(ctx, in, query)
+		if err != nil {
+			return nil, err
+		}
+		return format.Apply(result), nil
+	}
+}
+
+// Returns how numeric results are written: ?decimals=2 fixes the digits after the
+// point, ?digits=3 the significant digits. Exactly as computed if neither is given.
+func numberFormat(query url.Values) (matrix.NumberFormat, error) {
+	var f matrix.NumberFormat
+	if query.Has("decimals") && query.Has("digits") {
+		return f, fmt.Errorf("error: give one of decimals or digits, not both")
+	}
+	for _, p := range []struct {
+		key   string
+		round matrix.Rounding
+		min   int
+	}{{"decimals", matrix.RoundDecimals, 0}, {"digits", matrix.RoundDigits, 1}} {
+		if !query.Has(p.key) {
+			continue
+		}
+		n, err := strconv.Atoi(query.Get(p.key))
+		if err != nil || n < p.min || n > maxPlaces {
+			return f, fmt.Errorf("error: %s must be a whole number from %d to %d, got '%s'", p.key, p.min, maxPlaces, query.Get(p.key))
+		}
+		f = matrix.NumberFormat{Round: p.round, Places: n}
+	}
+	return f, nil
+}
+
+// Parses every input's cells into T.
+// Text operations get their inputs as loaded, without a copy.
+func parseAll[T matrix.Element](in []*matrix.Matrix[string]) ([]*matrix.Matrix[T], error) {
+	if text, ok := any(in).([]*matrix.Matrix[T]); ok {
+		return text, nil
+	}
+	parsed := make([]*matrix.Matrix[T], len(in))
+	for i, m := range in {
+		p, err := matrix.FromRecords[T](m.Data)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = p
+	}
+	return parsed, nil
+}
+
+// Wraps a single value as a 1x1 matrix.
+func scalar(v string) *matrix.Matrix[string] {
+	return &matrix.Matrix[string]{Data: [][]string{{v}}, Rows: 1, Cols: 1}
+}
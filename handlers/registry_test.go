@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"league_challenge/matrix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRegistryRoutes ensures every name and alias of every registered
+// operation is routed, so a registration alone is enough to expose it.
+func TestRegistryRoutes(t *testing.T) {
+	for _, op := range operations {
+		for _, name := range op.names() {
+			t.Run(name, func(t *testing.T) {
+				req := newMultipartRequest(t, "/"+name, nil)
+				rec := httptest.NewRecorder()
+
+				newTestMux().ServeHTTP(rec, req)
+
+				// no upload, so a routed operation asks for its first input
+				want := "must upload form file with key '" + op.inputs()[0] + "'"
+				if body := rec.Body.String(); rec.Code != http.StatusBadRequest || !strings.Contains(body, want) {
+					t.Fatalf("expected 400 containing %q, got %d %q", want, rec.Code, body)
+				}
+			})
+		}
+	}
+}
+
+// TestHelp checks the listing carries a line per operation with its declared
+// shape, element and result requirements.
+func TestHelp(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/help", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{"route", "/inverse,/invert", "/matmul", "a,b", "NxN", "rational", "scalar", "/pipeline", "/matrices", "/jobs", "/cache", "/metrics"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected help containing %q, got:\n%s", want, body)
+		}
+	}
+	if lines := strings.Count(body, "\n"); lines != len(operations)+6 {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(operations)+6, lines, body)
+	}
+}
+
+// TestNegotiate covers Accept header matching, wildcards, q-values and the
+// 406 returned when no supported type is acceptable.
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{name: "no header", accept: "", want: "text/csv"},
+		{name: "exact", accept: "text/csv", want: "text/csv"},
+		{name: "wildcard", accept: "*/*", want: "text/csv"},
+		{name: "major wildcard", accept: "text/*;q=0.5", want: "text/csv"},
+		{name: "json", accept: "application/json", want: "application/json"},
+		{name: "preferred json", accept: "text/csv;q=0.5, application/json", want: "application/json"},
+		{name: "preferred unsupported", accept: "application/xml, text/csv;q=0.1", want: "text/csv"},
+		{name: "unsupported", accept: "application/xml", wantErr: true},
+		{name: "excluded", accept: "text/csv;q=0", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/echo", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			got, err := negotiate(req)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestNotAcceptable confirms an operation refuses to run when the client
+// accepts none of the response types.
+func TestNotAcceptable(t *testing.T) {
+	content := sampleMatrixCSV
+	req := newMultipartRequest(t, "/echo", &content)
+	req.Header.Set("Accept", "image/png")
+	rec := httptest.NewRecorder()
+
+	newTestMux().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected status 406, got %d", rec.Code)
+	}
+}
+
+// TestMethods checks uploads are POST only, /help is GET only, and the mux
+// answers anything else with 405 and an Allow header listing what is accepted.
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		method    string
+		target    string
+		wantCode  int
+		wantAllow string
+	}{
+		{method: http.MethodGet, target: "/echo", wantCode: http.StatusMethodNotAllowed, wantAllow: "OPTIONS, POST"},
+		{method: http.MethodPut, target: "/invert", wantCode: http.StatusMethodNotAllowed, wantAllow: "OPTIONS, POST"},
+		{method: http.MethodDelete, target: "/pipeline", wantCode: http.StatusMethodNotAllowed, wantAllow: "OPTIONS, POST"},
+		{method: http.MethodPost, target: "/help", wantCode: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{method: http.MethodHead, target: "/help", wantCode: http.StatusOK},
+		{method: http.MethodOptions, target: "/matmul", wantCode: http.StatusOK, wantAllow: "OPTIONS, POST"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestMux().ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d", tc.wantCode, rec.Code)
+			}
+			if allow := rec.Header().Get("Allow"); allow != tc.wantAllow {
+				t.Fatalf("expected Allow %q, got %q", tc.wantAllow, allow)
+			}
+		})
+	}
+}
+
+// TestOptions checks OPTIONS describes the accepted request and response types,
+// naming the operation's own inputs.
+func TestOptions(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestMux().ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/matmul", nil))
+
+	if accept := rec.Header().Get("Accept-Post"); accept != "multipart/form-data, application/json" {
+		t.Fatalf("expected Accept-Post listing request types, got %q", accept)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"POST /matmul", "keys a,b", "text/csv, application/json"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected options containing %q, got:\n%s", want, body)
+		}
+	}
+}
+
+// TestConfigTimeout checks route limits are looked up by name, then by the
+// operation an alias belongs to, then fall back to the default.
+func TestConfigTimeout(t *testing.T) {
+	cfg := Config{Timeout: time.Minute, Timeouts: map[string]time.Duration{"inverse": time.Hour, "pipeline": time.Second}}
+	tests := map[string]time.Duration{
+		"inverse":  time.Hour,
+		"invert":   time.Hour,
+		"pipeline": time.Second,
+		"det":      time.Minute,
+	}
+	for name, want := range tests {
+		if got := cfg.timeout(name); got != want {
+			t.Fatalf("expected %s timeout %s, got %s", name, want, got)
+		}
+	}
+}
+
+// TestTimeouts checks an operation past its route's deadline, or whose client
+// has gone, stops with a 504 or 503 problem while other routes are unaffected.
+func TestTimeouts(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{Timeouts: map[string]time.Duration{"det": time.Nanosecond, "pipeline": time.Nanosecond}})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		target      string
+		ctx         context.Context
+		wantCode    int
+		wantProblem string
+	}{
+		{name: "deadline", target: "/det", ctx: context.Background(), wantCode: http.StatusGatewayTimeout, wantProblem: "timeout"},
+		{name: "pipeline deadline", target: "/pipeline?ops=transpose,rank", ctx: context.Background(), wantCode: http.StatusGatewayTimeout, wantProblem: "timeout"},
+		{name: "client gone", target: "/rank", ctx: cancelled, wantCode: http.StatusServiceUnavailable, wantProblem: "canceled"},
+		{name: "unlimited route", target: "/rank", ctx: context.Background(), wantCode: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := sampleMatrixCSV
+			req := newMultipartRequest(t, tc.target, &content).WithContext(tc.ctx)
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if tc.wantProblem == "" {
+				return
+			}
+			var p problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("expected problem body: %v", err)
+			}
+			if p.Code != tc.wantProblem {
+				t.Fatalf("expected code %q, got %q", tc.wantProblem, p.Code)
+			}
+		})
+	}
+}
+
+// TestUploadLimits checks oversized bodies, whether declared up front or
+// streamed, and matrices over a dimension limit are rejected with 413.
+func TestUploadLimits(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{MaxBytes: 1024, Limits: matrix.Limits{MaxRows: 3, MaxCols: 3, MaxCellLen: 8}})
+	big := strings.Repeat("1,", 600) + "1\n"
+
+	tests := []struct {
+		name      string
+		request   func() *http.Request
+		wantCode  int
+		wantLimit float64
+		wantRow   float64
+	}{
+		{
+			name:     "within limits",
+			request:  func() *http.Request { content := sampleMatrixCSV; return newMultipartRequest(t, "/echo", &content) },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "declared length",
+			request:   func() *http.Request { return newMultipartRequest(t, "/echo", &big) },
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantLimit: 1024,
+		},
+		{
+			name: "streamed length",
+			request: func() *http.Request {
+				req := newMultipartRequest(t, "/echo", &big)
+				req.ContentLength = -1
+				return req
+			},
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantLimit: 1024,
+		},
+		{
+			name: "rows",
+			request: func() *http.Request {
+				content := "1\n2\n3\n4\n"
+				return newMultipartRequest(t, "/pipeline?ops=echo", &content)
+			},
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantLimit: 3,
+			wantRow:   4,
+		},
+		{
+			name: "json cell",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"matrix": [[1, 123456789]]}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantLimit: 8,
+			wantRow:   1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, tc.request())
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if tc.wantCode == http.StatusOK {
+				return
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("expected problem body: %v", err)
+			}
+			if body["code"] != "too-large" || body["limit"] != tc.wantLimit {
+				t.Fatalf("expected too-large with limit %v, got %v", tc.wantLimit, body)
+			}
+			if tc.wantRow != 0 && body["row"] != tc.wantRow {
+				t.Fatalf("expected row %v, got %v", tc.wantRow, body["row"])
+			}
+		})
+	}
+}
+
+// TestCompressedUploads sends gzip files, gzip bodies and a zip bomb, checking
+// they are decompressed within the byte limit, and a plain file that starts like zlib.
+func TestCompressedUploads(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{MaxBytes: 1024, StreamAbove: 512})
+	gz := func(s string) string {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Write([]byte(s))
+		zw.Close()
+		return b.String()
+	}
+	bomb := gz(strings.Repeat("0,", 10000) + "0\n")
+
+	tests := []struct {
+		name     string
+		request  func() *http.Request
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "gzip file",
+			request: func() *http.Request {
+				content := gz(sampleMatrixCSV)
+				return newMultipartRequest(t, "/transpose", &content)
+			},
+			wantCode: http.StatusOK,
+			wantBody: "1,4,7\n2,5,8\n3,6,9\n",
+		},
+		{
+			name: "gzip bomb streamed",
+			request: func() *http.Request {
+				req := newMultipartRequest(t, "/add", &bomb)
+				req.Header.Del("Content-Length")
+				req.ContentLength = -1
+				return req
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: "upload decompresses to more than 1024 bytes",
+		},
+		{
+			name: "tsv file with a zlib header",
+			request: func() *http.Request {
+				content := "X\tY\n1\t2\n"
+				return newMultipartRequest(t, "/echo?header=true", &content)
+			},
+			wantCode: http.StatusOK,
+			wantBody: "X,Y\n1,2\n",
+		},
+		{
+			name:     "gzip bomb",
+			request:  func() *http.Request { return newMultipartRequest(t, "/add", &bomb) },
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `"limit":1024`,
+		},
+		{
+			name: "gzip body",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/det", strings.NewReader(gz(`{"matrix": [[1,2],[3,4]]}`)))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Content-Encoding", "gzip")
+				return req
+			},
+			wantCode: http.StatusOK,
+			wantBody: "-2",
+		},
+		{
+			name: "unsupported encoding",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/det", strings.NewReader(`{"matrix": [[1]]}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Content-Encoding", "br")
+				return req
+			},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantBody: `"code":"unsupported-encoding"`,
+		},
+		{
+			name: "corrupt gzip body",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/det", strings.NewReader(`{"matrix": [[1]]}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Content-Encoding", "gzip")
+				return req
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid gzip upload",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, tc.request())
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body containing %q, got %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
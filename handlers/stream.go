@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"league_challenge/matrix"
+	"league_challenge/middleware"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+/*
+	This file has the streaming path, taken by operations with a Stream func when
+	a csv upload is answered in csv. Rows are read from the multipart body and
+	results written to the response as they are produced.
+	Errors found before any output are problem responses as usual. An error after
+	output has started aborts the connection, so a client never mistakes a
+	truncated result for a complete one.
+*/
+
+// Reports whether the request can take op's streaming path. Uploads declaring
+// a Content-Length of at most Config.StreamAbove are loaded whole instead, so
+// their results can be cached, as are uploads with a ?missing= policy, which
+// may need a whole column to fill a cell.
+func (op Operation) streams(r *http.Request, contentType string) bool {
+	if op.Stream == nil || contentType != "text/csv" || r.URL.Query().Has("missing") {
+		return false
+	}
+	if r.ContentLength >= 0 && r.ContentLength <= configFrom(r.Context()).StreamAbove {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// Streams the upload through op.Stream into the response.
+func (op Operation) serveStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	d, err := dialectFor(r)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+	s, err := matrix.NewScannerFromForm(r, op.inputs()[0], d, configFrom(ctx).Limits)
+	if err != nil {
+		writeProblem(w, r, problemFor(err))
+		return
+	}
+
+	// buffered so a failure in the first few rows can still be a problem response
+	out := &streamWriter{w: w, contentType: "text/csv"}
+	buf := bufio.NewWriter(out)
+	err = op.Stream(ctx, s, buf, r.URL.Query())
+	if err == nil {
+		err = buf.Flush()
+	}
+	middleware.AddAttrs(ctx, slog.String("matrix_size", fmt.Sprintf("%dx%d", s.Rows(), s.Cols())), slog.Bool("streamed", true))
+	if s.Rows() > 0 {
+		observeSize(ctx, s.Rows(), s.Cols())
+	}
+
+	switch {
+	case err == nil:
+		out.start()
+	case !out.started:
+		writeProblem(w, r, problemFor(err))
+	default:
+		observeParseError(ctx, err)
+		middleware.Logger(ctx).Error("stream failed", slog.String("error", err.Error()))
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// streamWriter writes the 200 status and content type ahead of the first byte of output.
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	sw.start()
+	return sw.w.Write(b)
+}
+
+func (sw *streamWriter) start() {
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", sw.contentType)
+		sw.w.WriteHeader(http.StatusOK)
+		sw.started = true
+	}
+}
+
+// Adapts a function writing rows as they are scanned, eg: matrix.ScanEcho
+func rowwise(fn func(ctx context.Context, s *matrix.Scanner, w io.Writer) error) StreamFunc {
+	return func(ctx context.Context, s *matrix.Scanner, w io.Writer, _ url.Values) error {
+		return fn(ctx, s, w)
+	}
+}
+
+// Streams a reduction over int64 cells by default, or big.Int cells with ?precision=big,
+// promoted as by integers, writing the single value per ?decimals= or ?digits=.
+func scanIntegers(small, exact func(context.Context, *matrix.Scanner) (string, error)) StreamFunc {
+	return func(ctx context.Context, s *matrix.Scanner, w io.Writer, query url.Values) error {
+		format, err := numberFormat(query)
+		if err != nil {
+			return err
+		}
+		var v string
+		switch precision := query.Get("precision"); precision {
+		case "", "int":
+			v, err = small(ctx, s)
+		case "big":
+			v, err = exact(ctx, s)
+		default:
+			return fmt.Errorf("error: unknown precision '%s'. must be one of 'int' or 'big'", precision)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, format.Format(v))
+		return err
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestStreamErrors checks a failure before any output is a problem response,
+// while one after output has started aborts the response rather than end it
+// cleanly, and is counted as aborted.
+func TestStreamErrors(t *testing.T) {
+	t.Run("before output", func(t *testing.T) {
+		content := "1,2\n3,4,5\n"
+		rec := httptest.NewRecorder()
+		newTestMux().ServeHTTP(rec, newMultipartRequest(t, "/echo", &content))
+
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Fatalf("expected 400 problem, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("after output", func(t *testing.T) {
+		content := strings.Repeat("1,2\n", 5000) + "3,4,5\n"
+		mux := newTestMux()
+		rec := httptest.NewRecorder()
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expected the response to be aborted, got %v", v)
+			}
+			if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "1,2\n1,2\n") {
+				t.Fatalf("expected partial output before the abort, got %d", rec.Code)
+			}
+
+			metrics := httptest.NewRecorder()
+			mux.ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if want := `matrix_requests_total{op="echo",status="aborted"} 1` + "\n"; !strings.Contains(metrics.Body.String(), want) {
+				t.Fatalf("expected %q in:\n%s", want, metrics.Body.String())
+			}
+		}()
+
+		mux.ServeHTTP(rec, newMultipartRequest(t, "/echo", &content))
+	})
+}
+
+// TestStreamLargeUpload runs the streamed operations over a matrix far larger
+// than the response buffer, checking the row-wise results.
+func TestStreamLargeUpload(t *testing.T) {
+	content := strings.Repeat("1,1,1,1\n", 10000)
+	tests := map[string]func(body string) bool{
+		"/echo":    func(body string) bool { return body == content },
+		"/flatten": func(body string) bool { return len(body) == 2*40000-1 },
+		"/add":     func(body string) bool { return body == "40000" },
+		"/mul":     func(body string) bool { return body == "1" },
+	}
+
+	for target, check := range tests {
+		t.Run(target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestMux().ServeHTTP(rec, newMultipartRequest(t, target, &content))
+
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" {
+				t.Fatalf("expected 200 text/csv, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			if !check(rec.Body.String()) {
+				t.Fatalf("unexpected body for %s, %d bytes", target, rec.Body.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+	This package runs work in the background, for operations that take longer
+	than an HTTP client will wait. Tasks are submitted to a Queue, which runs them
+	on a fixed number of workers, holding at most depth tasks waiting their turn.
+	A Job is a snapshot of a task's status, progress and outcome, polled by ID.
+*/
+
+// Status is where a job is in its life.
+type Status string
+
+const (
+	Queued   Status = "queued"
+	Running  Status = "running"
+	Done     Status = "done"
+	Failed   Status = "failed"
+	Canceled Status = "canceled"
+)
+
+// retain is how long finished jobs are kept for polling before they are pruned.
+const retain = time.Hour
+
+// pruneEvery is how often finished jobs are pruned while nothing else prunes them.
+const pruneEvery = time.Minute
+
+var (
+	// ErrNotFound is returned for an ID the queue does not hold.
+	ErrNotFound = errors.New("error: job not found")
+
+	// ErrQueueFull is returned by Submit when depth tasks are already waiting.
+	ErrQueueFull = errors.New("error: job queue is full. try again later")
+
+	// ErrClosed is returned by Submit once the queue is closed.
+	ErrClosed = errors.New("error: job queue is closed")
+)
+
+// Task is the work of a job. It should stop and return ctx.Err() once ctx is
+// done, and may call progress with the fraction done, from 0 to 1.
+type Task func(ctx context.Context, progress func(fraction float64)) (any, error)
+
+// Job is a snapshot of a submitted task.
+type Job struct {
+	ID       string
+	Name     string // what the task does, eg: the operation name
+	Status   Status
+	Progress float64 // fraction done, 1 once finished
+	Result   any     // the task's result once Done
+	Err      error   // the task's error once Failed
+	Created  time.Time
+	Started  time.Time
+	Finished time.Time
+}
+
+// Reports whether the job has stopped, successfully or not.
+func (j Job) Stopped() bool {
+	return j.Status == Done || j.Status == Failed || j.Status == Canceled
+}
+
+// Queue runs submitted tasks on a fixed pool of workers.
+type Queue struct {
+	tasks  chan *entry
+	mu     sync.Mutex
+	jobs   map[string]*entry
+	closed bool
+	done   chan struct{} // closed by Close, stops the pruning
+	wg     sync.WaitGroup
+}
+
+// entry is a job with the task and cancellation behind it. Guarded by Queue.mu.
+// task is nil once the job stops, so the upload it holds can be freed.
+type entry struct {
+	job    Job
+	task   Task
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Returns a Queue running tasks on workers goroutines, with at most depth waiting.
+// Close it to stop the workers.
+func NewQueue(workers, depth int) *Queue {
+	q := &Queue{tasks: make(chan *entry, depth), jobs: map[string]*entry{}, done: make(chan struct{})}
+	for range max(workers, 1) {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.pruneLoop()
+	return q
+}
+
+// Queues task under name and returns its Job, or ErrQueueFull.
+func (q *Queue) Submit(name string, task Task) (Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &entry{
+		job:    Job{ID: newID(), Name: name, Status: Queued, Created: time.Now()},
+		task:   task,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		cancel()
+		return Job{}, ErrClosed
+	}
+	q.prune()
+	select {
+	case q.tasks <- e:
+	default:
+		cancel()
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[e.job.ID] = e
+	return e.job, nil
+}
+
+// Returns the job with id, or ErrNotFound.
+func (q *Queue) Get(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+	e, ok := q.jobs[id]
+	if !ok {
+		return Job{}, notFound(id)
+	}
+	return e.job, nil
+}
+
+// Cancels a queued or running job, which is Canceled from then on, and returns it.
+// A finished job is removed instead, and returned as it was.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return Job{}, notFound(id)
+	}
+	if e.job.Stopped() {
+		delete(q.jobs, id)
+		return e.job, nil
+	}
+	e.stop(Canceled)
+	return e.job, nil
+}
+
+// Cancels every unfinished job and waits for the workers to stop.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	for _, e := range q.jobs {
+		if !e.job.Stopped() {
+			e.stop(Canceled)
+		}
+	}
+	close(q.tasks)
+	close(q.done)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// Runs tasks until the queue is closed.
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for e := range q.tasks {
+		q.run(e)
+	}
+}
+
+// Runs a single task, recording its progress and outcome unless it was canceled.
+func (q *Queue) run(e *entry) {
+	q.mu.Lock()
+	if e.job.Status == Canceled {
+		q.mu.Unlock()
+		return
+	}
+	e.job.Status, e.job.Started = Running, time.Now()
+	task := e.task
+	q.mu.Unlock()
+
+	progress := func(fraction float64) {
+		q.mu.Lock()
+		// parallel operations report out of order, progress only moves forward
+		if e.job.Status == Running && fraction > e.job.Progress {
+			e.job.Progress = fraction
+		}
+		q.mu.Unlock()
+	}
+	result, err := task(e.ctx, progress)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if e.job.Status == Canceled {
+		return
+	}
+	if err != nil {
+		e.job.Err = err
+		e.stop(Failed)
+		return
+	}
+	e.job.Progress, e.job.Result = 1, result
+	e.stop(Done)
+}
+
+// Marks the job stopped with status, canceling its context and dropping its task.
+// Called with Queue.mu held.
+func (e *entry) stop(status Status) {
+	e.cancel()
+	e.task = nil
+	e.job.Status, e.job.Finished = status, time.Now()
+}
+
+// Prunes finished jobs every pruneEvery until the queue is closed, so they are
+// freed even once no more jobs are submitted or polled.
+func (q *Queue) pruneLoop() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(pruneEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+			q.mu.Lock()
+			q.prune()
+			q.mu.Unlock()
+		}
+	}
+}
+
+// Removes jobs finished more than retain ago. Called with q.mu held.
+func (q *Queue) prune() {
+	for id, e := range q.jobs {
+		if e.job.Stopped() && time.Since(e.job.Finished) > retain {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// Returns ErrNotFound naming id.
+func notFound(id string) error {
+	return fmt.Errorf("%w. no job with id '%s'", ErrNotFound, id)
+}
+
+// Returns a random 16 character hex ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls the job until it stops, failing the test after a second.
+func waitFor(t *testing.T, q *Queue, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		job, err := q.Get(id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if job.Stopped() {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+// blocker returns a task that reports half way, then waits for release or its context.
+func blocker(release <-chan struct{}) Task {
+	return func(ctx context.Context, progress func(float64)) (any, error) {
+		progress(0.5)
+		select {
+		case <-release:
+			return "released", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TestQueue covers a job's life: queued, running with progress, then done or failed.
+func TestQueue(t *testing.T) {
+	q := NewQueue(1, 4)
+	defer q.Close()
+
+	release := make(chan struct{})
+	first, err := q.Submit("block", blocker(release))
+	if err != nil || first.Status != Queued {
+		t.Fatalf("expected a queued job, got %+v %v", first, err)
+	}
+	failing, _ := q.Submit("fail", func(context.Context, func(float64)) (any, error) {
+		return nil, errors.New("error: boom")
+	})
+
+	// the single worker is busy with first, so failing waits its turn
+	deadline := time.Now().Add(time.Second)
+	for job, _ := q.Get(first.ID); job.Progress != 0.5; job, _ = q.Get(first.ID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected first to report progress, got %+v", job)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if job, _ := q.Get(failing.ID); job.Status != Queued {
+		t.Fatalf("expected the second job to wait, got %s", job.Status)
+	}
+
+	close(release)
+	if job := waitFor(t, q, first.ID); job.Status != Done || job.Result != "released" || job.Progress != 1 {
+		t.Fatalf("expected done with result, got %+v", job)
+	}
+	if job := waitFor(t, q, failing.ID); job.Status != Failed || job.Err == nil {
+		t.Fatalf("expected failed with error, got %+v", job)
+	}
+
+	if _, err := q.Get("0123456789abcdef"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestQueueCancel checks running and queued jobs stop on Cancel, and that
+// cancelling a finished job removes it.
+func TestQueueCancel(t *testing.T) {
+	q := NewQueue(1, 4)
+	defer q.Close()
+
+	running, _ := q.Submit("block", blocker(nil))
+	queued, _ := q.Submit("block", blocker(nil))
+
+	for _, id := range []string{queued.ID, running.ID} {
+		job, err := q.Cancel(id)
+		if err != nil || job.Status != Canceled {
+			t.Fatalf("expected canceled, got %+v %v", job, err)
+		}
+	}
+	if job := waitFor(t, q, running.ID); job.Status != Canceled {
+		t.Fatalf("expected the running job to stay canceled, got %s", job.Status)
+	}
+
+	if _, err := q.Cancel(running.ID); err != nil {
+		t.Fatalf("unexpected error removing a finished job: %v", err)
+	}
+	if _, err := q.Get(running.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a removed job to be gone, got %v", err)
+	}
+}
+
+// TestQueueFull checks Submit refuses work beyond the queue depth, and after Close.
+func TestQueueFull(t *testing.T) {
+	q := NewQueue(1, 1)
+
+	release := make(chan struct{})
+	defer close(release)
+	first, _ := q.Submit("block", blocker(release))
+	for job, _ := q.Get(first.ID); job.Status != Running; job, _ = q.Get(first.ID) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := q.Submit("block", blocker(release)); err != nil {
+		t.Fatalf("expected room for one waiting job, got %v", err)
+	}
+	if _, err := q.Submit("block", blocker(release)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	q.Close()
+	if _, err := q.Submit("block", blocker(release)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+// TestQueuePrune checks a finished job drops its task at once, and the job
+// itself once it is older than retain, on the next Get.
+func TestQueuePrune(t *testing.T) {
+	q := NewQueue(1, 4)
+	defer q.Close()
+
+	job, _ := q.Submit("quick", func(context.Context, func(float64)) (any, error) { return 1, nil })
+	waitFor(t, q, job.ID)
+	q.mu.Lock()
+	e := q.jobs[job.ID]
+	if e.task != nil {
+		t.Errorf("expected the finished job's task to be dropped")
+	}
+	e.job.Finished = time.Now().Add(-retain - time.Second)
+	q.mu.Unlock()
+
+	if _, err := q.Get(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the old job to be pruned, got %v", err)
+	}
+}
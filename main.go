@@ -1,21 +1,115 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"league_challenge/cache"
 	"league_challenge/handlers"
+	"league_challenge/jobs"
+	"league_challenge/matrix"
+	"league_challenge/middleware"
+	"league_challenge/store"
+	"log/slog"
 	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
 )
 
 // Run with
 //		go run .
 // Send request with:
 //		curl -F 'file=@/path/matrix.csv' "localhost:8080/echo"
+//		curl -F 'a=@/path/a.csv' -F 'b=@/path/b.csv' "localhost:8080/matmul"
+//		curl -F 'file=@/path/matrix.csv' "localhost:8080/pipeline?ops=transpose,rotate90,flatten"
+// List every operation with:
+//		curl "localhost:8080/help"
+// Limit how long operations run with:
+//		go run . -timeout 30s -route-timeout det=2m -route-timeout pipeline=1m
+// Limit upload sizes with:
+//		go run . -max-bytes 10485760 -max-rows 10000 -max-cols 10000 -max-cell 256
+// Upload other csv dialects with:
+//		curl -F 'file=@/path/export.csv' "localhost:8080/add?delimiter=semicolon&header=true"
+//		curl -F 'file=@/path/confusion.csv' "localhost:8080/transpose?header=true&labels=true"
+// Round decimal results with:
+//		curl -F 'file=@/path/matrix.csv' "localhost:8080/add?decimals=2"
+// Fill empty cells with:
+//		curl -F 'file=@/path/matrix.csv' "localhost:8080/add?missing=row-mean"
+// Upload compressed matrices with:
+//		curl -F 'file=@/path/matrix.csv.gz' "localhost:8080/transpose"
+// Keep stored matrices across restarts with:
+//		go run . -store ./data
+// Run long operations in the background with:
+//		curl -F 'file=@/path/matrix.csv' "localhost:8080/jobs?op=inverse"
+//		curl "localhost:8080/jobs/{id}"
+// Scrape Prometheus metrics from:
+//		curl "localhost:8080/metrics"
+// Cache results of uploads up to 1MiB, streaming larger ones, with:
+//		go run . -cache-cells 1048576 -stream-above 1048576
 
 func main() {
-	http.HandleFunc("/echo", handlers.Echo)
-	http.HandleFunc("/invert", handlers.Transpose)
-	http.HandleFunc("/transpose", handlers.Transpose)
-	http.HandleFunc("/flatten", handlers.Flatten)
-	http.HandleFunc("/add", handlers.Addition)
-	http.HandleFunc("/mul", handlers.Multiply)
-	http.ListenAndServe(":8080", nil)
+	cfg := handlers.Config{
+		Timeout:  30 * time.Second,
+		Timeouts: map[string]time.Duration{},
+		MaxBytes: 10 << 20,
+		Limits:   matrix.Limits{MaxRows: 10000, MaxCols: 10000, MaxCellLen: 256},
+
+		JobTimeout:  10 * time.Minute,
+		StreamAbove: 1 << 20,
+	}
+	flag.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "time limit for every operation, 0 for none")
+	flag.Int64Var(&cfg.MaxBytes, "max-bytes", cfg.MaxBytes, "request body limit in bytes, 0 for none")
+	flag.IntVar(&cfg.Limits.MaxRows, "max-rows", cfg.Limits.MaxRows, "rows per uploaded matrix, 0 for none")
+	flag.IntVar(&cfg.Limits.MaxCols, "max-cols", cfg.Limits.MaxCols, "columns per uploaded matrix, 0 for none")
+	flag.IntVar(&cfg.Limits.MaxCellLen, "max-cell", cfg.Limits.MaxCellLen, "bytes per cell, 0 for none")
+	storeDir := flag.String("store", "", "directory keeping /matrices across restarts, in memory if empty")
+	jobWorkers := flag.Int("job-workers", runtime.GOMAXPROCS(0), "jobs run at once")
+	jobQueue := flag.Int("job-queue", 64, "jobs waiting for a worker before POST /jobs is refused")
+	flag.DurationVar(&cfg.JobTimeout, "job-timeout", cfg.JobTimeout, "time limit for each job, 0 for none")
+	cacheCells := flag.Int("cache-cells", 1<<20, "result cells kept for repeated uploads, 0 for none")
+	flag.Int64Var(&cfg.StreamAbove, "stream-above", cfg.StreamAbove, "uploads larger than this many bytes stream uncached, 0 to always stream")
+	flag.Func("route-timeout", "time limit for one route as name=duration, eg: det=2m. repeatable", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("expected name=duration, got '%s'", s)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		cfg.Timeouts[name] = d
+		return nil
+	})
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	if *storeDir != "" {
+		s, err := store.NewFile(*storeDir)
+		if err != nil {
+			logger.Error("cannot open store", slog.Any("error", err))
+			os.Exit(1)
+		}
+		cfg.Store = s
+	}
+	cfg.Jobs = jobs.NewQueue(*jobWorkers, *jobQueue)
+	cfg.Cache = cache.NewLRU[string, *matrix.Matrix[string]](*cacheCells)
+
+	mux := http.NewServeMux()
+	handlers.Register(mux, cfg)
+
+	// request ID first so every log line, including panics, carries it
+	handler := middleware.Chain(mux,
+		middleware.RequestID,
+		middleware.AccessLog(logger),
+		middleware.Recover,
+	)
+
+	logger.Info("listening", slog.String("addr", ":8080"))
+	if err := http.ListenAndServe(":8080", handler); err != nil {
+		logger.Error("server stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
 }
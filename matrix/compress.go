@@ -0,0 +1,165 @@
+package matrix
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+/*
+	This file has transparent decompression of uploads.
+	A form file is sniffed by its magic bytes, so matrix.csv.gz, a zlib stream or
+	a zip holding a single csv parse as the plain csv would. Plain text can start
+	with a valid zlib header, eg: X\t, so a zlib stream must also inflate. A request body is
+	decoded by its Content-Encoding instead, see DecodeContent.
+	Decompressed bytes count against Limits.MaxDecompressed, so a small upload
+	cannot expand into an unbounded one (a zip bomb).
+*/
+
+// Returns a reader over r decompressed if its first bytes mark it as gzip, zlib
+// or zip, or over r unchanged otherwise. Bytes that start like zlib but do not
+// inflate are plain.
+func Decompress(r io.Reader, limits Limits) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4) // shorter at EOF, an empty upload is plain
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gunzip(br, limits)
+	case isZlib(magic) && inflates(br):
+		return inflate(br, limits)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")): // an empty archive is only its directory
+		return unzip(br, limits)
+	}
+	return br, nil
+}
+
+// Returns a reader over r decoded as a Content-Encoding header describes, eg:
+// "gzip" or "deflate". Several encodings are undone last first, as RFC 9110 lists
+// them in the order applied.
+func DecodeContent(r io.Reader, encoding string, limits Limits) (io.Reader, error) {
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			r, err = gunzip(r, limits)
+		case "deflate":
+			r, err = inflate(r, limits)
+		default:
+			return nil, fmt.Errorf("%w '%s'. supported encodings are gzip, deflate and identity", ErrUnsupportedEncoding, coding)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func gunzip(r io.Reader, limits Limits) (io.Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid gzip upload. %w", err)
+	}
+	return limits.decompressed(zr), nil
+}
+
+func inflate(r io.Reader, limits Limits) (io.Reader, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid zlib upload. %w", err)
+	}
+	return limits.decompressed(zr), nil
+}
+
+// Opens the single csv in a zip archive. The archive is read into memory, its
+// directory being at the end, so it is bounded by the request's byte limit.
+// Directories and macOS resource forks (__MACOSX/, ._name) are skipped.
+func unzip(r io.Reader, limits Limits) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid zip upload. %w", err)
+	}
+
+	var files []*zip.File
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(path.Base(f.Name), "._") {
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("error: invalid zip upload. must hold a single csv file, found %d", len(files))
+	}
+
+	rc, err := files[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid zip upload. %w", err)
+	}
+	return limits.decompressed(rc), nil
+}
+
+// Reports whether magic starts a zlib stream: deflate with a window of at most
+// 32KiB, no preset dictionary and a valid header checksum (RFC 1950).
+func isZlib(magic []byte) bool {
+	if len(magic) < 2 {
+		return false
+	}
+	cmf, flg := magic[0], magic[1]
+	return cmf&0x0f == 8 && cmf>>4 <= 7 && flg&0x20 == 0 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
+
+// Reports whether the bytes buffered in br inflate, as far as they go. Text with
+// a zlib header, eg: X\tY, is corrupt deflate within a few bytes.
+func inflates(br *bufio.Reader) bool {
+	head, _ := br.Peek(br.Size())
+	zr, err := zlib.NewReader(bytes.NewReader(head))
+	if err == nil {
+		// bounded, a few KiB of deflate can expand a thousandfold
+		_, err = io.Copy(io.Discard, io.LimitReader(zr, 1<<16))
+	}
+	return err == nil || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Returns r bounded by MaxDecompressed, unbounded if it is zero.
+func (l Limits) decompressed(r io.Reader) io.Reader {
+	if l.MaxDecompressed <= 0 {
+		return r
+	}
+	return &decompressedReader{r: r, max: l.MaxDecompressed}
+}
+
+// decompressedReader fails with a LimitError once more than max bytes are read.
+type decompressedReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (d *decompressedReader) Read(p []byte) (int, error) {
+	if d.n > d.max {
+		return 0, &LimitError{Limit: "bytes", Max: int(d.max)}
+	}
+	// read at most one byte past the limit, to tell reaching it from breaking it
+	if left := d.max - d.n + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := d.r.Read(p)
+	d.n += int64(n)
+	if d.n > d.max {
+		return n - int(d.n-d.max), &LimitError{Limit: "bytes", Max: int(d.max)}
+	}
+	return n, err
+}
@@ -0,0 +1,148 @@
+package matrix
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func gzipped(s string) string {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(s))
+	zw.Close()
+	return b.String()
+}
+
+func zlibbed(s string) string {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write([]byte(s))
+	zw.Close()
+	return b.String()
+}
+
+// Returns a zip archive of files, name to content. A name ending in / is a directory.
+func zipped(files ...[2]string) string {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, f := range files {
+		w, _ := zw.Create(f[0])
+		w.Write([]byte(f[1]))
+	}
+	zw.Close()
+	return b.String()
+}
+
+// TestDecompress checks each format is sniffed and decompressed, plain csv
+// passes through, and a bomb stops at MaxDecompressed.
+func TestDecompress(t *testing.T) {
+	const csv = "1,2\n3,4\n"
+	limits := Limits{MaxDecompressed: 64}
+
+	tests := []struct {
+		name    string
+		upload  string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", upload: csv, want: csv},
+		{name: "plain starting with x", upload: "x,1\n", want: "x,1\n"},
+		{name: "empty", upload: "", want: ""},
+		{name: "tsv with a zlib header", upload: "X\tY\n1\t2\n", want: "X\tY\n1\t2\n"},
+		{name: "text with zlib headers", upload: "H\r\nhC,x^,(S,8O\r\n", want: "H\r\nhC,x^,(S,8O\r\n"},
+		{name: "gzip", upload: gzipped(csv), want: csv},
+		{name: "zlib", upload: zlibbed(csv), want: csv},
+		{name: "zip", upload: zipped([2]string{"m.csv", csv}), want: csv},
+		{name: "zip from macOS", upload: zipped([2]string{"out/", ""}, [2]string{"out/m.csv", csv}, [2]string{"__MACOSX/out/._m.csv", "junk"}), want: csv},
+		{name: "zip of two", upload: zipped([2]string{"a.csv", csv}, [2]string{"b.csv", csv}), wantErr: "must hold a single csv file, found 2"},
+		{name: "empty zip", upload: zipped(), wantErr: "found 0"},
+		{name: "gzip bomb", upload: gzipped(strings.Repeat("0,", 1000)), wantErr: "upload decompresses to more than 64 bytes"},
+		{name: "zip bomb", upload: zipped([2]string{"m.csv", strings.Repeat("0,", 1000)}), wantErr: "upload decompresses to more than 64 bytes"},
+		{name: "corrupt gzip", upload: gzipped(csv)[:20], wantErr: "unexpected EOF"},
+		{name: "truncated zlib", upload: zlibbed(csv)[:8], wantErr: "unexpected EOF"},
+		{name: "zlib bomb", upload: zlibbed(strings.Repeat("0,", 1000)), wantErr: "upload decompresses to more than 64 bytes"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []byte
+			r, err := Decompress(strings.NewReader(tc.upload), limits)
+			if err == nil {
+				got, err = io.ReadAll(r)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestDecompressLimit checks the limit error, and that exactly the limit is allowed.
+func TestDecompressLimit(t *testing.T) {
+	exact, err := Decompress(strings.NewReader(gzipped(strings.Repeat("1", 64))), Limits{MaxDecompressed: 64})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := io.ReadAll(exact); err != nil || len(got) != 64 {
+		t.Fatalf("expected all 64 bytes, got %d, %v", len(got), err)
+	}
+
+	over, _ := Decompress(strings.NewReader(gzipped(strings.Repeat("1", 65))), Limits{MaxDecompressed: 64})
+	got, err := io.ReadAll(over)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrTooLarge) || limitErr.Limit != "bytes" || limitErr.Max != 64 {
+		t.Fatalf("expected a bytes LimitError, got %v", err)
+	}
+	if len(got) != 64 {
+		t.Fatalf("expected reading to stop at the limit, got %d bytes", len(got))
+	}
+}
+
+// TestDecodeContent checks Content-Encoding values, including lists.
+func TestDecodeContent(t *testing.T) {
+	const csv = "1,2\n"
+	tests := []struct {
+		encoding string
+		body     string
+		wantErr  error
+	}{
+		{encoding: "gzip", body: gzipped(csv)},
+		{encoding: "x-gzip", body: gzipped(csv)},
+		{encoding: "deflate", body: zlibbed(csv)},
+		{encoding: "identity", body: csv},
+		{encoding: "deflate, gzip", body: gzipped(zlibbed(csv))},
+		{encoding: "br", body: csv, wantErr: ErrUnsupportedEncoding},
+	}
+	for _, tc := range tests {
+		t.Run(tc.encoding, func(t *testing.T) {
+			r, err := DecodeContent(strings.NewReader(tc.body), tc.encoding, Limits{})
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, _ := io.ReadAll(r); string(got) != csv {
+				t.Fatalf("expected %q, got %q", csv, got)
+			}
+		})
+	}
+}
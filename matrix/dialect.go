@@ -0,0 +1,121 @@
+package matrix
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+/*
+	This file has csv dialects: the delimiter, comments, quoting and header row
+	of an upload. The zero Dialect suits most files, its delimiter is sniffed
+	from the first lines, so semicolon separated exports from European
+	spreadsheets and tab separated files parse without being told.
+	A UTF-8 byte order mark, as Excel writes, is always stripped.
+*/
+
+// Dialect describes how a csv upload is written.
+type Dialect struct {
+	Delimiter  rune // separates cells, 0 sniffs it, see sniffDelimiter
+	Comment    rune // lines starting with it are skipped, 0 for none
+	LazyQuotes bool // a quote may appear in an unquoted cell, and unescaped in a quoted one
+	Header     bool // the first row names the columns and is not part of the matrix
+	Labels     bool // the first column names the rows and is not part of the matrix
+}
+
+// sniffedDelimiters are the delimiters tried, in order of preference.
+// ',' is last since it is the decimal separator where ';' is the delimiter, eg: 1,5;2,5
+var sniffedDelimiters = []rune{'\t', ';', '|', ','}
+
+// sniffSize is how much of an upload is looked at to sniff its delimiter.
+const sniffSize = 16 << 10
+
+// utf8BOM is the byte order mark some editors write at the start of UTF-8 files.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// Returns an error if d cannot be read by encoding/csv, eg: a newline delimiter.
+func (d Dialect) Validate() error {
+	invalid := func(r rune) bool {
+		return r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError || !utf8.ValidRune(r)
+	}
+	if d.Delimiter != 0 && invalid(d.Delimiter) {
+		return fmt.Errorf("error: invalid delimiter %q", d.Delimiter)
+	}
+	if d.Comment != 0 && invalid(d.Comment) {
+		return fmt.Errorf("error: invalid comment character %q", d.Comment)
+	}
+	if d.Comment != 0 && d.Comment == d.Delimiter {
+		return fmt.Errorf("error: comment character and delimiter must differ, both are %q", d.Comment)
+	}
+	return nil
+}
+
+// Strips a byte order mark from br, then fills in a missing delimiter from the
+// first lines of br without consuming them. A read error is left in br, for the
+// csv reader to meet in order, so rows before it are still read and checked.
+func (d Dialect) prepare(br *bufio.Reader) Dialect {
+	if bom, _ := br.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	if d.Delimiter == 0 {
+		sample, err := br.Peek(sniffSize)
+		d.Delimiter = sniffDelimiter(sample, d.Comment, err == io.EOF)
+	}
+	return d
+}
+
+// Returns the first of sniffedDelimiters found the same, non-zero, number of
+// times on every sampled line, quotes and comment lines aside. ',' if none is.
+// The last line only counts if complete is true, as it may be cut short.
+func sniffDelimiter(sample []byte, comment rune, complete bool) rune {
+	lines := bytes.Split(sample, []byte("\n"))
+	if !complete || len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	counts := make([][]int, 0, len(lines))
+	for _, line := range lines {
+		line = bytes.TrimRight(line, "\r")
+		if len(bytes.TrimSpace(line)) == 0 || (comment != 0 && bytes.HasPrefix(line, []byte(string(comment)))) {
+			continue
+		}
+		counts = append(counts, countUnquoted(line))
+	}
+
+next:
+	for i, delim := range sniffedDelimiters {
+		if len(counts) == 0 || counts[0][i] == 0 {
+			continue
+		}
+		for _, c := range counts[1:] {
+			if c[i] != counts[0][i] {
+				continue next
+			}
+		}
+		return delim
+	}
+	return ','
+}
+
+// Returns how often each of sniffedDelimiters appears in line outside quotes.
+func countUnquoted(line []byte) []int {
+	counts := make([]int, len(sniffedDelimiters))
+	quoted := false
+	for _, r := range string(line) {
+		if r == '"' {
+			quoted = !quoted
+			continue
+		}
+		if quoted {
+			continue
+		}
+		for i, delim := range sniffedDelimiters {
+			if r == delim {
+				counts[i]++
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,89 @@
+package matrix
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSniffDelimiter checks the delimiter found for common exports.
+func TestSniffDelimiter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{name: "comma", sample: "1,2,3\n4,5,6\n", want: ','},
+		{name: "semicolon", sample: "1;2;3\n4;5;6\n", want: ';'},
+		{name: "semicolon with decimal commas", sample: "1,5;2,25\n3;4,75\n", want: ';'},
+		{name: "tab", sample: "1\t2\n3\t4\n", want: '\t'},
+		{name: "pipe", sample: "1|2\n3|4\n", want: '|'},
+		{name: "single column", sample: "1\n2\n", want: ','},
+		{name: "empty", sample: "", want: ','},
+		{name: "quoted delimiters ignored", sample: "\"a;b\",1\n\"c;d\",2\n", want: ','},
+		{name: "inconsistent counts", sample: "1;2,3\n4,5;6;7\n", want: ','},
+		{name: "comment lines ignored", sample: "# a;b;c\n1,2\n3,4\n", want: ','},
+		{name: "cut off last line ignored", sample: "1;2\n3;4\n5", want: ';'},
+		{name: "crlf", sample: "1;2\r\n3;4\r\n", want: ';'},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sniffDelimiter([]byte(tc.sample), '#', false); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestScannerDialects reads the same 2x2 matrix written in several dialects.
+func TestScannerDialects(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		input      string
+		dialect    Dialect
+		want       string
+		wantHeader string
+		wantErr    string
+	}{
+		{name: "default", input: "1,2\n3,4\n", want: "1|2 3|4"},
+		{name: "byte order mark", input: "\ufeff1;2\n3;4\n", want: "1|2 3|4"},
+		{name: "sniffed semicolon", input: "1,5;2\n3;4\n", want: "1,5|2 3|4"},
+		{name: "given delimiter", input: "1;2\n3;4\n", dialect: Dialect{Delimiter: ','}, want: "1;2 3;4"},
+		{name: "tab", input: "1\t2\n3\t4\n", dialect: Dialect{Delimiter: '\t'}, want: "1|2 3|4"},
+		{name: "comments", input: "# exported\n1,2\n# totals\n3,4\n", dialect: Dialect{Comment: '#'}, want: "1|2 3|4"},
+		{name: "header", input: "\ufeffa;b\n1;2\n3;4\n", dialect: Dialect{Header: true}, want: "1|2 3|4", wantHeader: "a|b"},
+		{name: "header only", input: "a,b\n", dialect: Dialect{Header: true}, wantHeader: "a|b", wantErr: ErrEmptyMatrix.Error()},
+		{name: "strict quotes", input: "1,2\"\n3,4\n", wantErr: "bare \" in non-quoted-field"},
+		{name: "lazy quotes", input: "1,2\"\n3,4\n", dialect: Dialect{LazyQuotes: true}, want: "1|2\" 3|4"},
+		{name: "newline delimiter", input: "1,2\n", dialect: Dialect{Delimiter: '\n'}, wantErr: "invalid delimiter"},
+		{name: "comment is delimiter", input: "1,2\n", dialect: Dialect{Delimiter: ';', Comment: ';'}, wantErr: "must differ"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewScanner(strings.NewReader(tc.input), tc.dialect, Limits{})
+			var rows []string
+			for s.Scan() {
+				rows = append(rows, strings.Join(s.Row(), "|"))
+			}
+			err := s.end()
+
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := strings.Join(rows, " "); got != tc.want {
+				t.Fatalf("rows mismatch: want %q got %q", tc.want, got)
+			}
+			if got := strings.Join(s.Header(), "|"); got != tc.wantHeader {
+				t.Fatalf("header mismatch: want %q got %q", tc.wantHeader, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package matrix
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/*
+	This file defines the element types a Matrix can hold and, for each of them,
+	how cells are parsed from csv, written back out, and combined arithmetically.
+*/
+
+// Number is the set of element types arithmetic is defined for.
+// *big.Int is the arbitrary-precision fallback for int64 arithmetic that overflows.
+type Number interface {
+	int64 | *big.Int | float64 | complex128 | *big.Rat
+}
+
+// Element is the set of types a Matrix can hold.
+// string matrices support the structural operations only (Echo, Flatten, Transpose).
+type Element interface {
+	string | Number
+}
+
+// arithmetic bundles the per-type behaviour needed by the generic Matrix.
+// add and mul are nil for non-numeric types, and report false when the
+// result does not fit in T: fixed-width ints wrapping, or float64 reaching ±Inf.
+type arithmetic[T Element] struct {
+	name   string
+	parse  func(s string) (T, error)
+	format func(v T) string
+	zero   func() T
+	one    func() T
+	add    func(a, b T) (T, bool)
+	mul    func(a, b T) (T, bool)
+}
+
+var stringArith = arithmetic[string]{
+	name:   "string",
+	parse:  func(s string) (string, error) { return s, nil },
+	format: func(v string) string { return v },
+	zero:   func() string { return "" },
+	one:    func() string { return "" },
+}
+
+var intArith = arithmetic[int64]{
+	name:   "int",
+	parse:  func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) },
+	format: func(v int64) string { return strconv.FormatInt(v, 10) },
+	zero:   func() int64 { return 0 },
+	one:    func() int64 { return 1 },
+	add:    addInt64,
+	mul:    mulInt64,
+}
+
+var bigIntArith = arithmetic[*big.Int]{
+	name: "int",
+	parse: func(s string) (*big.Int, error) {
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, strconv.ErrSyntax
+		}
+		return v, nil
+	},
+	format: func(v *big.Int) string { return v.String() },
+	zero:   func() *big.Int { return new(big.Int) },
+	one:    func() *big.Int { return big.NewInt(1) },
+	add:    func(a, b *big.Int) (*big.Int, bool) { return new(big.Int).Add(a, b), true },
+	mul:    func(a, b *big.Int) (*big.Int, bool) { return new(big.Int).Mul(a, b), true },
+}
+
+var floatArith = arithmetic[float64]{
+	name:   "float",
+	parse:  parseFloat,
+	format: func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) },
+	zero:   func() float64 { return 0 },
+	one:    func() float64 { return 1 },
+	add:    func(a, b float64) (float64, bool) { return finite(a + b) },
+	mul:    func(a, b float64) (float64, bool) { return finite(a * b) },
+}
+
+var complexArith = arithmetic[complex128]{
+	name:   "complex",
+	parse:  func(s string) (complex128, error) { return strconv.ParseComplex(s, 128) },
+	format: func(v complex128) string { return strconv.FormatComplex(v, 'g', -1, 128) },
+	zero:   func() complex128 { return 0 },
+	one:    func() complex128 { return 1 },
+	add:    func(a, b complex128) (complex128, bool) { return a + b, true },
+	mul:    func(a, b complex128) (complex128, bool) { return a * b, true },
+}
+
+var ratArith = arithmetic[*big.Rat]{
+	name:   "numeric",
+	parse:  parseRat,
+	format: func(v *big.Rat) string { return v.RatString() },
+	zero:   func() *big.Rat { return new(big.Rat) },
+	one:    func() *big.Rat { return big.NewRat(1, 1) },
+	add:    func(a, b *big.Rat) (*big.Rat, bool) { return new(big.Rat).Add(a, b), true },
+	mul:    func(a, b *big.Rat) (*big.Rat, bool) { return new(big.Rat).Mul(a, b), true },
+}
+
+// arith returns the arithmetic for the element type T.
+func arith[T Element]() arithmetic[T] {
+	var zero T
+	var a any
+	switch any(zero).(type) {
+	case string:
+		a = stringArith
+	case int64:
+		a = intArith
+	case *big.Int:
+		a = bigIntArith
+	case float64:
+		a = floatArith
+	case complex128:
+		a = complexArith
+	case *big.Rat:
+		a = ratArith
+	}
+	return a.(arithmetic[T])
+}
+
+// Format returns the csv representation of a single element.
+func Format[T Element](v T) string {
+	return arith[T]().format(v)
+}
+
+// toRat converts a numeric element to an exact rational.
+// Returns false for strings, complex values and non-finite floats.
+func toRat[T Element](v T) (*big.Rat, bool) {
+	switch x := any(v).(type) {
+	case int64:
+		return new(big.Rat).SetInt64(x), true
+	case *big.Int:
+		return new(big.Rat).SetInt(x), true
+	case float64:
+		r := new(big.Rat).SetFloat64(x)
+		return r, r != nil
+	case *big.Rat:
+		return new(big.Rat).Set(x), true
+	}
+	return nil, false
+}
+
+// parseFloat parses a finite float64. strconv.ParseFloat alone also takes nan, inf
+// and infinity, which no sum or product of them can mean.
+func parseFloat(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		return 0, strconv.ErrSyntax
+	}
+	return v, err
+}
+
+// finite returns v, and whether it is neither ±Inf nor NaN.
+func finite(v float64) (float64, bool) {
+	return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// parseRat parses a decimal, eg: 1.5 or 2e3, or a fraction of decimal integers, eg: -1/3.
+// big.Rat.SetString alone also takes base prefixes, reading 010/1 as 8 and 0x10 as 16.
+func parseRat(s string) (*big.Rat, error) {
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		a, okNum := new(big.Int).SetString(num, 10)
+		b, okDen := new(big.Int).SetString(den, 10)
+		if !okNum || !okDen || b.Sign() <= 0 || strings.HasPrefix(den, "+") {
+			return nil, strconv.ErrSyntax
+		}
+		return new(big.Rat).SetFrac(a, b), nil
+	}
+	if strings.ContainsFunc(s, func(r rune) bool { return !strings.ContainsRune("0123456789+-.eE", r) }) {
+		return nil, strconv.ErrSyntax
+	}
+	v, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, strconv.ErrSyntax
+	}
+	return v, nil
+}
+
+// addInt64 adds two int64s, reporting false if the sum overflows.
+func addInt64(a, b int64) (int64, bool) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// mulInt64 multiplies two int64s, reporting false if the product overflows.
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	// MinInt64 * -1 wraps back to MinInt64, which the division check misses
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	c := a * b
+	if c/b != a {
+		return 0, false
+	}
+	return c, true
+}
@@ -0,0 +1,111 @@
+package matrix
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	This file has the errors returned by the matrix package.
+	Sentinels are matched with errors.Is, the typed errors carry the details
+	(which cell, which shape) with errors.As.
+*/
+
+var (
+	// ErrEmptyMatrix is returned when an upload has no rows.
+	ErrEmptyMatrix = errors.New("error: empty matrix")
+
+	// ErrNotSquare is returned by operations that are only defined for NxN matrices.
+	ErrNotSquare = errors.New("error: not an NxN matrix")
+
+	// ErrOverflow is returned when a result does not fit in the matrix element type.
+	ErrOverflow = errors.New("error: overflow. result does not fit in int64, or is too large for float64")
+
+	// ErrSingular is returned by Inverse when the matrix has no inverse.
+	ErrSingular = errors.New("error: matrix is singular and cannot be inverted")
+
+	// ErrTooLarge is returned when an upload breaks one of its Limits.
+	ErrTooLarge = errors.New("error: matrix too large")
+
+	// ErrMissingCell is returned when an empty cell breaks the Missing policy in force.
+	ErrMissingCell = errors.New("error: missing value in matrix")
+
+	// ErrUnsupportedEncoding is returned by DecodeContent for an unknown Content-Encoding.
+	ErrUnsupportedEncoding = errors.New("error: unsupported content encoding")
+)
+
+// NotSquareError is returned by square-only operations given a MxN matrix.
+// It matches ErrNotSquare with errors.Is.
+type NotSquareError struct {
+	Op   string
+	Rows int
+	Cols int
+}
+
+func (e *NotSquareError) Error() string {
+	return fmt.Sprintf("%s. %s requires a square matrix, got %dx%d", ErrNotSquare, e.Op, e.Rows, e.Cols)
+}
+
+func (e *NotSquareError) Unwrap() error {
+	return ErrNotSquare
+}
+
+// NonNumericCellError is returned when a cell cannot be parsed as the type an operation needs.
+// Row and Col are 1-based, as in a spreadsheet.
+type NonNumericCellError struct {
+	Row   int
+	Col   int
+	Value string
+	Type  string // the type the cell had to be, eg: "int"
+}
+
+func (e *NonNumericCellError) Error() string {
+	return fmt.Sprintf("error: non-%s values in matrix. row %d, col %d has %q", e.Type, e.Row, e.Col, e.Value)
+}
+
+// MissingCellError is returned by FillMissing for an empty cell its policy cannot fill.
+// It matches ErrMissingCell with errors.Is. Row and Col are 1-based.
+type MissingCellError struct {
+	Row    int
+	Col    int
+	Reason string // why it could not be filled, "" if the policy rejects every empty cell
+}
+
+func (e *MissingCellError) Error() string {
+	msg := fmt.Sprintf("%s. row %d, col %d is empty", ErrMissingCell, e.Row, e.Col)
+	if e.Reason != "" {
+		msg += " and " + e.Reason
+	}
+	return msg
+}
+
+func (e *MissingCellError) Unwrap() error {
+	return ErrMissingCell
+}
+
+// LimitError is returned when an upload breaks one of its Limits.
+// It matches ErrTooLarge with errors.Is. Row and Col are 1-based, Col is 0 unless
+// a single cell is at fault.
+type LimitError struct {
+	Limit string // "rows", "cols", "cell" or "bytes"
+	Max   int
+	Got   int
+	Row   int
+	Col   int
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case "rows":
+		return fmt.Sprintf("%s. more than %d rows", ErrTooLarge, e.Max)
+	case "cols":
+		return fmt.Sprintf("%s. row %d has %d columns, the limit is %d", ErrTooLarge, e.Row, e.Got, e.Max)
+	case "bytes":
+		return fmt.Sprintf("%s. upload decompresses to more than %d bytes", ErrTooLarge, e.Max)
+	}
+	return fmt.Sprintf("%s. row %d, col %d is %d bytes, the limit is %d", ErrTooLarge, e.Row, e.Col, e.Got, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrTooLarge
+}
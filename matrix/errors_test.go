@@ -0,0 +1,50 @@
+package matrix
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestNonNumericCellError checks a parse failure can be unpacked with
+// errors.As to find the exact offending cell.
+func TestNonNumericCellError(t *testing.T) {
+	_, err := FromRecords[int64]([][]string{{"1", "2", "3"}, {"4", "5", "six"}})
+
+	var cellErr *NonNumericCellError
+	if !errors.As(err, &cellErr) {
+		t.Fatalf("expected NonNumericCellError, got %v", err)
+	}
+	want := NonNumericCellError{Row: 2, Col: 3, Value: "six", Type: "int"}
+	if *cellErr != want {
+		t.Fatalf("expected %+v, got %+v", want, *cellErr)
+	}
+
+	// complex cells are numeric but never take part in real elimination,
+	// so the first cell is reported even when its imaginary part is zero
+	c, err := FromRecords[complex128]([][]string{{"1", "2i"}, {"3", "4"}})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	_, err = c.Determinant()
+	if !errors.As(err, &cellErr) || cellErr.Row != 1 || cellErr.Col != 1 || cellErr.Value != "(1+0i)" || cellErr.Type != "real" {
+		t.Fatalf("expected real NonNumericCellError at row 1, col 1, got %v", err)
+	}
+}
+
+// TestNotSquareError checks the typed shape error still matches the
+// ErrNotSquare sentinel and carries the actual shape.
+func TestNotSquareError(t *testing.T) {
+	err := matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}}).RequireSquare("trace")
+
+	if !errors.Is(err, ErrNotSquare) {
+		t.Fatalf("expected ErrNotSquare, got %v", err)
+	}
+	var shapeErr *NotSquareError
+	if !errors.As(err, &shapeErr) || shapeErr.Rows != 2 || shapeErr.Cols != 3 || shapeErr.Op != "trace" {
+		t.Fatalf("expected NotSquareError for 2x3 trace, got %v", err)
+	}
+
+	if _, err := FromRecords[string](nil); !errors.Is(err, ErrEmptyMatrix) {
+		t.Fatalf("expected ErrEmptyMatrix, got %v", err)
+	}
+}
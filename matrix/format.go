@@ -0,0 +1,97 @@
+package matrix
+
+import (
+	"math/big"
+)
+
+/*
+	This file has the formatting of numeric results.
+	Results are computed exactly, or as float64, and written as computed unless
+	a NumberFormat rounds them, eg: to 2 decimals or 3 significant digits.
+	Rounding works on the exact decimal value of a cell, so big integers and
+	fractions round as precisely as floats do.
+*/
+
+// Rounding is how a NumberFormat rounds numbers.
+type Rounding int
+
+const (
+	RoundExact    Rounding = iota // as computed: integers and fractions whole, floats the shortest decimal reading back as the same float64
+	RoundDecimals                 // Places digits after the point, eg: 2 writes 1.50
+	RoundDigits                   // Places significant digits, eg: 3 writes 1.23e+04
+)
+
+// NumberFormat is how numeric results are written. The zero NumberFormat writes them exactly.
+type NumberFormat struct {
+	Round  Rounding
+	Places int
+}
+
+// Returns cell written per f, halves rounded away from zero. Cells that are not
+// finite numbers, eg: NaN or text, are returned as is.
+func (f NumberFormat) Format(cell string) string {
+	if f.Round == RoundExact {
+		return cell
+	}
+	r, err := parseRat(cell)
+	if err != nil {
+		return cell
+	}
+	if f.Round == RoundDecimals {
+		return r.FloatString(f.Places)
+	}
+	// rounded exactly first, Text alone would round halves to even
+	return new(big.Float).SetPrec(256).SetRat(roundDigits(r, f.Places)).Text('g', f.Places)
+}
+
+// Returns r rounded to digits significant digits, halves away from zero.
+func roundDigits(r *big.Rat, digits int) *big.Rat {
+	if r.Sign() == 0 {
+		return r
+	}
+	// find e with 10^e <= |r| < 10^(e+1), starting from the digit counts
+	abs := new(big.Rat).Abs(r)
+	e := len(abs.Num().String()) - len(abs.Denom().String())
+	for abs.Cmp(pow10(e)) < 0 {
+		e--
+	}
+	for abs.Cmp(pow10(e+1)) >= 0 {
+		e++
+	}
+	scale := pow10(digits - 1 - e)
+	scaled := new(big.Rat).Mul(r, scale)
+	return scaled.SetInt(roundRat(scaled)).Quo(scaled, scale)
+}
+
+// Rounds r to the nearest integer, halves away from zero.
+func roundRat(r *big.Rat) *big.Int {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	return q
+}
+
+// Returns 10^n, n may be negative.
+func pow10(n int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(n, -n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+// Returns m with every cell written per f, m itself if f is exact.
+func (f NumberFormat) Apply(m *Matrix[string]) *Matrix[string] {
+	if f.Round == RoundExact {
+		return m
+	}
+	out := &Matrix[string]{Data: make([][]string, m.Rows), Rows: m.Rows, Cols: m.Cols, RowLabels: m.RowLabels, ColLabels: m.ColLabels}
+	for i, row := range m.Data {
+		out.Data[i] = make([]string, len(row))
+		for j, cell := range row {
+			out.Data[i][j] = f.Format(cell)
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package matrix
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestNumberFormat checks fixed decimals and significant digits round halves
+// away from zero, and that exact formats and non-numbers are left alone.
+func TestNumberFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format NumberFormat
+		cell   string
+		want   string
+	}{
+		{format: NumberFormat{}, cell: "0.1", want: "0.1"},
+		{format: NumberFormat{}, cell: "1/3", want: "1/3"},
+		{format: NumberFormat{Round: RoundDecimals, Places: 2}, cell: "1.005", want: "1.01"},
+		{format: NumberFormat{Round: RoundDecimals, Places: 2}, cell: "1/3", want: "0.33"},
+		{format: NumberFormat{Round: RoundDecimals, Places: 1}, cell: "7", want: "7.0"},
+		{format: NumberFormat{Round: RoundDecimals, Places: 0}, cell: "-2.5", want: "-3"},
+		{format: NumberFormat{Round: RoundDigits, Places: 3}, cell: "12345", want: "1.23e+04"},
+		{format: NumberFormat{Round: RoundDigits, Places: 2}, cell: "0.125", want: "0.13"},
+		{format: NumberFormat{Round: RoundDigits, Places: 1}, cell: "-95", want: "-1e+02"},
+		{format: NumberFormat{Round: RoundDigits, Places: 3}, cell: "1.5e-7", want: "1.5e-07"},
+		{format: NumberFormat{Round: RoundDigits, Places: 3}, cell: "0", want: "0"},
+		{format: NumberFormat{Round: RoundDigits, Places: 20}, cell: "123456789012345678901234567890", want: "1.234567890123456789e+29"},
+		{format: NumberFormat{Round: RoundDecimals, Places: 2}, cell: "+Inf", want: "+Inf"},
+		{format: NumberFormat{Round: RoundDecimals, Places: 2}, cell: "NA", want: "NA"},
+	}
+	for _, tc := range tests {
+		if got := tc.format.Format(tc.cell); got != tc.want {
+			t.Fatalf("%+v of %q: want %q, got %q", tc.format, tc.cell, tc.want, got)
+		}
+	}
+
+	m := &Matrix[string]{Data: [][]string{{"1/2", "2"}}, Rows: 1, Cols: 2}
+	got := NumberFormat{Round: RoundDecimals, Places: 1}.Apply(m)
+	if want := [][]string{{"0.5", "2.0"}}; !reflect.DeepEqual(want, got.Data) || m.Data[0][0] != "1/2" {
+		t.Fatalf("want %v with the input unchanged, got %v and %v", want, got.Data, m.Data)
+	}
+}
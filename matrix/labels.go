@@ -0,0 +1,59 @@
+package matrix
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+	This file has row and column labels, read from a csv header row and first
+	column with Dialect.Header and Dialect.Labels:
+		,x,y
+		a,1,2
+		b,3,4
+	is the 2x2 matrix 1,2 3,4 with ColLabels x,y and RowLabels a,b. The top left
+	cell names neither and is not kept. Labels are metadata: operations work on
+	Data alone, structural ones (Transpose, Rotate90) move the labels with the
+	cells and Echo writes them back out.
+*/
+
+// Reports whether m has row or column labels.
+func (m *Matrix[T]) Labelled() bool {
+	return m.RowLabels != nil || m.ColLabels != nil
+}
+
+// Sets labels on a matrix built from records, checking there is one per row and column.
+func (m *Matrix[T]) setLabels(rows, cols []string) error {
+	if rows != nil && len(rows) != m.Rows {
+		return fmt.Errorf("error: %d row labels for %d rows", len(rows), m.Rows)
+	}
+	if cols != nil && len(cols) != m.Cols {
+		return fmt.Errorf("error: %d column labels for %d columns", len(cols), m.Cols)
+	}
+	m.RowLabels, m.ColLabels = rows, cols
+	return nil
+}
+
+// Writes the header row of labels, after an empty top left cell if the rows are labelled too.
+func writeHeader(w io.StringWriter, labels []string, corner bool) {
+	if corner {
+		w.WriteString(",")
+	}
+	for j, label := range labels {
+		if j > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString(csvField(label))
+	}
+	w.WriteString("\n")
+}
+
+// Returns field, a label or cell, as a csv field, quoted if it holds a comma,
+// quote or line break, eg: a cell 2,5 uploaded in the semicolon dialect.
+func csvField(field string) string {
+	if !strings.ContainsAny(field, ",\"\r\n") {
+		return field
+	}
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
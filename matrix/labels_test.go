@@ -0,0 +1,103 @@
+package matrix
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestReadMatrixLabels checks the header row and label column are taken off the
+// data and kept as labels, in each combination.
+func TestReadMatrixLabels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		dialect Dialect
+		want    *Matrix[int64]
+		wantErr string
+	}{
+		{
+			name:    "header and labels",
+			input:   "actual\\predicted, cat, dog\ncat, 5, 1\ndog, 2, 7\n",
+			dialect: Dialect{Header: true, Labels: true},
+			want:    &Matrix[int64]{Data: [][]int64{{5, 1}, {2, 7}}, Rows: 2, Cols: 2, RowLabels: []string{"cat", "dog"}, ColLabels: []string{"cat", "dog"}},
+		},
+		{
+			name:    "header only",
+			input:   "x,y,z\n1,2,3\n",
+			dialect: Dialect{Header: true},
+			want:    &Matrix[int64]{Data: [][]int64{{1, 2, 3}}, Rows: 1, Cols: 3, ColLabels: []string{"x", "y", "z"}},
+		},
+		{
+			name:    "labels only",
+			input:   "a,1\n\"b,c\",2\n",
+			dialect: Dialect{Labels: true},
+			want:    &Matrix[int64]{Data: [][]int64{{1}, {2}}, Rows: 2, Cols: 1, RowLabels: []string{"a", "b,c"}},
+		},
+		{name: "label without cells", input: "a\nb\n", dialect: Dialect{Labels: true}, wantErr: "row 1 has a label but no cells"},
+		{name: "nothing but a header", input: "x,y\n", dialect: Dialect{Header: true}, wantErr: ErrEmptyMatrix.Error()},
+		{name: "non-numeric label without the dialect", input: ",x\na,1\n", wantErr: "non-int values in matrix. row 1, col 1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ReadMatrix[int64](strings.NewReader(tc.input), tc.dialect, Limits{})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("matrix mismatch: want %+v got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestLabelsThroughOperations checks Transpose swaps labels, Rotate90 turns
+// them, Strings keeps them, and Echo and ScanEcho write them back identically.
+func TestLabelsThroughOperations(t *testing.T) {
+	t.Parallel()
+
+	input := ",x,\"y,z\"\na,1,2\nb,3,4\nc,5,6\n"
+	d := Dialect{Header: true, Labels: true}
+	m, err := ReadMatrix[int64](strings.NewReader(input), d, Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := m.Echo(); got != input {
+		t.Fatalf("echo mismatch.\nwant:\n%s\ngot:\n%s", input, got)
+	}
+	var streamed strings.Builder
+	if err := ScanEcho(context.Background(), NewScanner(strings.NewReader(input), d, Limits{}), &streamed); err != nil || streamed.String() != input {
+		t.Fatalf("streamed echo mismatch.\nwant:\n%s\ngot:\n%s (%v)", input, streamed.String(), err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "transpose", got: m.Transpose().Echo(), want: ",a,b,c\nx,1,3,5\n\"y,z\",2,4,6\n"},
+		{name: "transpose twice", got: m.Transpose().Transpose().Echo(), want: input},
+		{name: "rotate90", got: m.Rotate90().Echo(), want: ",c,b,a\nx,5,3,1\n\"y,z\",6,4,2\n"},
+		{name: "strings", got: m.Strings().Transpose().Echo(), want: ",a,b,c\nx,1,3,5\n\"y,z\",2,4,6\n"},
+		{name: "flatten drops labels", got: m.Flatten(), want: "1,2,3,4,5,6"},
+		{name: "column labels only", got: (&Matrix[int64]{Data: [][]int64{{1, 2}}, Rows: 1, Cols: 2, ColLabels: []string{"p", "q"}}).Transpose().Echo(), want: "p,1\nq,2\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.got != tc.want {
+				t.Fatalf("mismatch.\nwant:\n%s\ngot:\n%s", tc.want, tc.got)
+			}
+		})
+	}
+}
@@ -1,85 +1,432 @@
 package matrix
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"math/big"
+	"slices"
 	"strings"
 )
 
 /*
 	This files contains the Matrix struct definition and methods acting on the matrix type
+	The costly operations have a ...Context variant checking ctx once per row, so
+	work stops soon after a deadline passes or the client goes away, and reporting
+	progress there when asked to (see WithProgress).
 */
 
-type Matrix struct {
-	Data [][]string
-	Size int
+// Matrix holds typed cell values, parsed once when the matrix is loaded.
+// Matrices are MxN, operations that need NxN check IsSquare themselves.
+// Labels are optional metadata naming the rows and columns, see labels.go.
+type Matrix[T Element] struct {
+	Data [][]T
+	Rows int
+	Cols int
+
+	RowLabels []string // one per row, nil if the rows are unlabelled
+	ColLabels []string // one per column, nil if the columns are unlabelled
+}
+
+// Reports whether the matrix has as many rows as columns.
+func (m *Matrix[T]) IsSquare() bool {
+	return m.Rows == m.Cols
+}
+
+// Returns a NotSquareError, naming the operation and actual shape, unless m is NxN.
+func (m *Matrix[T]) RequireSquare(op string) error {
+	if !m.IsSquare() {
+		return &NotSquareError{Op: op, Rows: m.Rows, Cols: m.Cols}
+	}
+	return nil
 }
 
 // Returns a string representation of the matrix.
-// Echo() is frequently used.
-func (m *Matrix) Echo() string {
+// Echo() is frequently used. Labels are written back as a header row and a
+// first column, as they were uploaded.
+func (m *Matrix[T]) Echo() string {
+	format := arith[T]().format
 	var b strings.Builder
-	for _, row := range m.Data {
-		b.WriteString(strings.Join(row, ","))
+	if m.ColLabels != nil {
+		writeHeader(&b, m.ColLabels, m.RowLabels != nil)
+	}
+	for i, row := range m.Data {
+		if m.RowLabels != nil {
+			b.WriteString(csvField(m.RowLabels[i]))
+			b.WriteByte(',')
+		}
+		for j, v := range row {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(csvField(format(v)))
+		}
 		b.WriteByte('\n')
 	}
 	return b.String()
 }
 
-// Transposes the matrix in-memory.
-// Returns nothing, use m.Echo() to print.
-func (m *Matrix) Transpose() {
-	for row := 0; row < m.Size; row++ {
-		for col := row + 1; col < m.Size; col++ {
-			m.Data[row][col], m.Data[col][row] = m.Data[col][row], m.Data[row][col]
+// Returns the transpose of a MxN matrix as a new NxM Matrix.
+// Rows become columns, and row labels column labels, m is left untouched.
+func (m *Matrix[T]) Transpose() *Matrix[T] {
+	data := make([][]T, m.Cols)
+	for col := range data {
+		data[col] = make([]T, m.Rows)
+		for row := range data[col] {
+			data[col][row] = m.Data[row][col]
+		}
+	}
+	return &Matrix[T]{Data: data, Rows: m.Cols, Cols: m.Rows, RowLabels: m.ColLabels, ColLabels: m.RowLabels}
+}
+
+// Returns the matrix rotated 90 degrees clockwise as a new NxM Matrix.
+// The first column, read bottom to top, becomes the first row. Column labels
+// name the new rows, row labels bottom to top the new columns.
+func (m *Matrix[T]) Rotate90() *Matrix[T] {
+	data := make([][]T, m.Cols)
+	for i := range data {
+		data[i] = make([]T, m.Rows)
+		for j := range data[i] {
+			data[i][j] = m.Data[m.Rows-1-j][i]
 		}
 	}
+	var colLabels []string
+	if m.RowLabels != nil {
+		colLabels = slices.Clone(m.RowLabels)
+		slices.Reverse(colLabels)
+	}
+	return &Matrix[T]{Data: data, Rows: m.Cols, Cols: m.Rows, RowLabels: m.ColLabels, ColLabels: colLabels}
+}
+
+// Returns a string Matrix holding the csv representation of every cell, and m's labels.
+// Lets results of typed operations be fed into further operations.
+func (m *Matrix[T]) Strings() *Matrix[string] {
+	format := arith[T]().format
+	data := make([][]string, m.Rows)
+	for i, row := range m.Data {
+		data[i] = make([]string, len(row))
+		for j, v := range row {
+			data[i][j] = format(v)
+		}
+	}
+	return &Matrix[string]{Data: data, Rows: m.Rows, Cols: m.Cols, RowLabels: m.RowLabels, ColLabels: m.ColLabels}
 }
 
 // Returns a flattened representation of the string.
 // Nested slices get reduced to single slice.
 // Sliced gets joined into a string.
-func (m *Matrix) Flatten() string {
+func (m *Matrix[T]) Flatten() string {
 	var totalElements int
 	for _, row := range m.Data {
 		totalElements += len(row)
 	}
 
+	format := arith[T]().format
 	retMatrix := make([]string, 0, totalElements)
 	for _, row := range m.Data {
-		retMatrix = append(retMatrix, row...)
+		for _, v := range row {
+			retMatrix = append(retMatrix, csvField(format(v)))
+		}
 	}
 	return strings.Join(retMatrix, ",")
 }
 
 // Returns the sum of all the values in the matrix.
-// Returns error if non-ints are encountered.
-func (m *Matrix) Add() (int, error) {
-	sum := 0
-	for _, row := range m.Data {
-		for j := range row {
-			v, err := strconv.Atoi(row[j])
-			if err != nil {
-				return 0, fmt.Errorf("error: non-int values in matrix. all values must be of type int for addition")
+// Returns error if the matrix is not numeric, or ErrOverflow if the sum does not fit in T.
+func (m *Matrix[T]) Add() (T, error) {
+	return m.AddContext(context.Background())
+}
+
+// Add, returning ctx.Err() if ctx is done before the sum is complete.
+func (m *Matrix[T]) AddContext(ctx context.Context) (T, error) {
+	a := arith[T]()
+	if a.add == nil {
+		return a.zero(), fmt.Errorf("error: non-numeric matrix. all values must be numeric for addition")
+	}
+	sum := a.zero()
+	for i, row := range m.Data {
+		if err := checkpoint(ctx, i, m.Rows); err != nil {
+			return a.zero(), err
+		}
+		for _, v := range row {
+			var ok bool
+			if sum, ok = a.add(sum, v); !ok {
+				return a.zero(), ErrOverflow
 			}
-			sum += v
 		}
 	}
 	return sum, nil
 }
 
 // Returns the product of all the values in the matrix
-// Returns error if non-ints are encountered.
-func (m *Matrix) Multiply() (int, error) {
-	prod := 1
-	for _, row := range m.Data {
-		for j := range row {
-			v, err := strconv.Atoi(row[j])
-			if err != nil {
-				return 0, fmt.Errorf("error: non-int values in matrix. all values must be of type int for multiplication")
+// Returns error if the matrix is not numeric, or ErrOverflow if the product does not fit in T.
+func (m *Matrix[T]) Multiply() (T, error) {
+	return m.MultiplyContext(context.Background())
+}
+
+// Multiply, returning ctx.Err() if ctx is done before the product is complete.
+func (m *Matrix[T]) MultiplyContext(ctx context.Context) (T, error) {
+	a := arith[T]()
+	if a.mul == nil {
+		return a.zero(), fmt.Errorf("error: non-numeric matrix. all values must be numeric for multiplication")
+	}
+	prod := a.one()
+	for i, row := range m.Data {
+		if err := checkpoint(ctx, i, m.Rows); err != nil {
+			return a.zero(), err
+		}
+		for _, v := range row {
+			var ok bool
+			if prod, ok = a.mul(prod, v); !ok {
+				return a.zero(), ErrOverflow
 			}
-			prod *= v
 		}
 	}
 	return prod, nil
 }
+
+// Returns the matrix product m×other as a new Matrix.
+// Returns error if the inner dimensions differ or the matrix is not numeric,
+// or ErrOverflow if any cell of the product does not fit in T.
+func (m *Matrix[T]) MatMul(other *Matrix[T]) (*Matrix[T], error) {
+	return m.MatMulContext(context.Background(), other)
+}
+
+// MatMul, returning ctx.Err() if ctx is done before the product is complete.
+func (m *Matrix[T]) MatMulContext(ctx context.Context, other *Matrix[T]) (*Matrix[T], error) {
+	a := arith[T]()
+	if a.add == nil {
+		return nil, fmt.Errorf("error: non-numeric matrix. all values must be numeric for matrix multiplication")
+	}
+	if m.Cols != other.Rows {
+		return nil, fmt.Errorf("error: inner dimensions do not match. cannot multiply %dx%d by %dx%d", m.Rows, m.Cols, other.Rows, other.Cols)
+	}
+
+	data := make([][]T, m.Rows)
+	for i := range data {
+		if err := checkpoint(ctx, i, m.Rows); err != nil {
+			return nil, err
+		}
+		data[i] = make([]T, other.Cols)
+		for j := range data[i] {
+			cell := a.zero()
+			for k := 0; k < m.Cols; k++ {
+				term, ok := a.mul(m.Data[i][k], other.Data[k][j])
+				if !ok {
+					return nil, ErrOverflow
+				}
+				if cell, ok = a.add(cell, term); !ok {
+					return nil, ErrOverflow
+				}
+			}
+			data[i][j] = cell
+		}
+	}
+	return &Matrix[T]{Data: data, Rows: m.Rows, Cols: other.Cols}, nil
+}
+
+// Returns the inverse of the matrix as a new Matrix, leaving m untouched.
+// Uses Gauss-Jordan elimination with partial pivoting over exact rationals,
+// so cells are written back as integers or fractions (eg: "1/2").
+// Returns ErrNotSquare for MxN input, or ErrSingular if the matrix has no inverse.
+func (m *Matrix[T]) Inverse() (*Matrix[*big.Rat], error) {
+	return m.InverseContext(context.Background())
+}
+
+// Inverse, returning ctx.Err() if ctx is done before the inverse is complete.
+func (m *Matrix[T]) InverseContext(ctx context.Context) (*Matrix[*big.Rat], error) {
+	if err := m.RequireSquare("inverse"); err != nil {
+		return nil, err
+	}
+	n := m.Rows
+	rows, err := m.rats()
+	if err != nil {
+		return nil, err
+	}
+
+	// build the augmented matrix [m | I]
+	aug := make([][]*big.Rat, n)
+	for i, row := range rows {
+		aug[i] = append(row, make([]*big.Rat, n)...)
+		for j := n; j < 2*n; j++ {
+			aug[i][j] = new(big.Rat)
+		}
+		aug[i][n+i].SetInt64(1)
+	}
+
+	tmp := new(big.Rat)
+	for col := 0; col < n; col++ {
+		if err := checkpoint(ctx, col, n); err != nil {
+			return nil, err
+		}
+
+		// partial pivoting: use the row with the largest magnitude in this column
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if tmp.Abs(aug[row][col]).Cmp(new(big.Rat).Abs(aug[pivot][col])) > 0 {
+				pivot = row
+			}
+		}
+		if aug[pivot][col].Sign() == 0 {
+			return nil, ErrSingular
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		// scale the pivot row so the pivot becomes 1
+		inv := new(big.Rat).Inv(aug[col][col])
+		for j := range aug[col] {
+			aug[col][j].Mul(aug[col][j], inv)
+		}
+
+		// eliminate this column from every other row
+		for row := 0; row < n; row++ {
+			factor := new(big.Rat).Set(aug[row][col])
+			if row == col || factor.Sign() == 0 {
+				continue
+			}
+			for j := range aug[row] {
+				aug[row][j].Sub(aug[row][j], tmp.Mul(factor, aug[col][j]))
+			}
+		}
+	}
+
+	// the right half of the augmented matrix is now the inverse
+	data := make([][]*big.Rat, n)
+	for i := range aug {
+		data[i] = aug[i][n:]
+	}
+	return &Matrix[*big.Rat]{Data: data, Rows: n, Cols: n}, nil
+}
+
+// Returns the sum of the main diagonal.
+// Returns ErrNotSquare for MxN input, or ErrOverflow if the sum does not fit in T.
+func (m *Matrix[T]) Trace() (T, error) {
+	a := arith[T]()
+	if a.add == nil {
+		return a.zero(), fmt.Errorf("error: non-numeric matrix. all values must be numeric for trace")
+	}
+	if err := m.RequireSquare("trace"); err != nil {
+		return a.zero(), err
+	}
+	sum := a.zero()
+	for i := 0; i < m.Rows; i++ {
+		var ok bool
+		if sum, ok = a.add(sum, m.Data[i][i]); !ok {
+			return a.zero(), ErrOverflow
+		}
+	}
+	return sum, nil
+}
+
+// Returns the exact determinant of the matrix.
+// Integer input stays integral throughout (see eliminate), so no precision is lost.
+// Returns ErrNotSquare for MxN input.
+func (m *Matrix[T]) Determinant() (*big.Rat, error) {
+	return m.DeterminantContext(context.Background())
+}
+
+// Determinant, returning ctx.Err() if ctx is done before elimination is complete.
+func (m *Matrix[T]) DeterminantContext(ctx context.Context) (*big.Rat, error) {
+	if err := m.RequireSquare("determinant"); err != nil {
+		return nil, err
+	}
+	rows, err := m.rats()
+	if err != nil {
+		return nil, err
+	}
+
+	rank, sign, err := eliminate(ctx, rows, m.Cols)
+	if err != nil {
+		return nil, err
+	}
+	if rank < m.Rows {
+		return new(big.Rat), nil
+	}
+	// the last Bareiss pivot is the determinant of the row-swapped matrix
+	det := new(big.Rat).Set(rows[m.Rows-1][m.Cols-1])
+	if sign < 0 {
+		det.Neg(det)
+	}
+	return det, nil
+}
+
+// Returns the rank of a MxN matrix, the number of linearly independent rows.
+func (m *Matrix[T]) Rank() (int, error) {
+	return m.RankContext(context.Background())
+}
+
+// Rank, returning ctx.Err() if ctx is done before elimination is complete.
+func (m *Matrix[T]) RankContext(ctx context.Context) (int, error) {
+	rows, err := m.rats()
+	if err != nil {
+		return 0, err
+	}
+	rank, _, err := eliminate(ctx, rows, m.Cols)
+	if err != nil {
+		return 0, err
+	}
+	return rank, nil
+}
+
+// Returns a copy of the matrix as exact rationals for elimination.
+// Returns a NonNumericCellError for the first cell that is not a real number.
+func (m *Matrix[T]) rats() ([][]*big.Rat, error) {
+	format := arith[T]().format
+	rows := make([][]*big.Rat, m.Rows)
+	for i, row := range m.Data {
+		rows[i] = make([]*big.Rat, len(row))
+		for j := range row {
+			v, ok := toRat(row[j])
+			if !ok {
+				return nil, &NonNumericCellError{Row: i + 1, Col: j + 1, Value: format(row[j]), Type: "real"}
+			}
+			rows[i][j] = v
+		}
+	}
+	return rows, nil
+}
+
+// Reduces rows to echelon form in-place with fraction-free Bareiss elimination.
+// Every division is exact, so integer input only ever produces integers.
+// Returns the rank and the sign (+1/-1) introduced by row swaps, or ctx.Err() if
+// ctx is done first.
+func eliminate(ctx context.Context, rows [][]*big.Rat, cols int) (rank int, sign int, err error) {
+	sign = 1
+	prev := big.NewRat(1, 1)
+	a, b := new(big.Rat), new(big.Rat)
+	for col := 0; col < cols && rank < len(rows); col++ {
+		if err := checkpoint(ctx, col, cols); err != nil {
+			return 0, 0, err
+		}
+
+		// find a row with a non-zero pivot in this column
+		pivot := -1
+		for i := rank; i < len(rows); i++ {
+			if rows[i][col].Sign() != 0 {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+		if pivot != rank {
+			rows[rank], rows[pivot] = rows[pivot], rows[rank]
+			sign = -sign
+		}
+
+		// M[i][j] = (M[r][c]*M[i][j] - M[i][c]*M[r][j]) / prev
+		p := rows[rank]
+		for i := rank + 1; i < len(rows); i++ {
+			for j := col + 1; j < cols; j++ {
+				a.Mul(p[col], rows[i][j])
+				b.Mul(rows[i][col], p[j])
+				rows[i][j].Sub(a, b)
+				rows[i][j].Quo(rows[i][j], prev)
+			}
+			rows[i][col].SetInt64(0)
+		}
+		prev = p[col]
+		rank++
+	}
+	return rank, sign, nil
+}
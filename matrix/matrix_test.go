@@ -1,34 +1,42 @@
 package matrix
 
 import (
-	"strconv"
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
 	"testing"
 )
 
-// matrixFromInts converts an integer grid into the Matrix format used by the
-// handlers. Tests rely on this helper to keep the happy-path setup compact
-// while still exercising the string parsing logic inside Add/Multiply.
-func matrixFromInts(rows [][]int) *Matrix {
-	data := make([][]string, len(rows))
+// matrixFromInts converts an integer grid into the int64 Matrix used by the
+// arithmetic handlers. Tests rely on this helper to keep the happy-path setup
+// compact.
+func matrixFromInts(rows [][]int) *Matrix[int64] {
+	data := make([][]int64, len(rows))
 	for i, row := range rows {
-		data[i] = make([]string, len(row))
+		data[i] = make([]int64, len(row))
 		for j, val := range row {
-			data[i][j] = strconv.Itoa(val)
+			data[i][j] = int64(val)
 		}
 	}
 
-	return &Matrix{
+	m := &Matrix[int64]{
 		Data: data,
-		Size: len(rows),
+		Rows: len(rows),
 	}
+	if len(rows) > 0 {
+		m.Cols = len(rows[0])
+	}
+	return m
 }
 
-// TestTranspose verifies that Transpose mutates the matrix in-place and that
-// the returned view from Echo matches the expected row/column orientation.
+// TestTranspose verifies that Transpose returns a new NxM matrix whose Echo
+// matches the expected row/column orientation, leaving the input untouched.
 func TestTranspose(t *testing.T) {
 	tests := []struct {
 		name   string
-		matrix *Matrix
+		matrix *Matrix[int64]
 		want   string
 	}{
 		{
@@ -41,14 +49,26 @@ func TestTranspose(t *testing.T) {
 			matrix: matrixFromInts([][]int{{4, 0, 0}, {0, -3, 0}, {0, 0, 7}}),
 			want:   "4,0,0\n0,-3,0\n0,0,7\n",
 		},
+		{
+			name:   "rectangular 2x3",
+			matrix: matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}}),
+			want:   "1,4\n2,5\n3,6\n",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.matrix.Transpose()
-			if got := tt.matrix.Echo(); got != tt.want {
+			before := tt.matrix.Echo()
+			transposed := tt.matrix.Transpose()
+			if got := transposed.Echo(); got != tt.want {
 				t.Fatalf("transpose mismatch.\nwant:\n%s\ngot:\n%s", tt.want, got)
 			}
+			if transposed.Rows != tt.matrix.Cols || transposed.Cols != tt.matrix.Rows {
+				t.Fatalf("expected %dx%d, got %dx%d", tt.matrix.Cols, tt.matrix.Rows, transposed.Rows, transposed.Cols)
+			}
+			if after := tt.matrix.Echo(); after != before {
+				t.Fatalf("transpose mutated the input matrix")
+			}
 		})
 	}
 }
@@ -58,7 +78,7 @@ func TestTranspose(t *testing.T) {
 func TestFlatten(t *testing.T) {
 	tests := []struct {
 		name   string
-		matrix *Matrix
+		matrix *Matrix[int64]
 		want   string
 	}{
 		{
@@ -87,8 +107,8 @@ func TestFlatten(t *testing.T) {
 func TestAdd(t *testing.T) {
 	tests := []struct {
 		name   string
-		matrix *Matrix
-		want   int
+		matrix *Matrix[int64]
+		want   int64
 	}{
 		{
 			name:   "positive values",
@@ -120,21 +140,21 @@ func TestAdd(t *testing.T) {
 	}
 }
 
-// TestAddError confirms we surface parsing errors rather than silently
-// skipping bad data and that the fallback sum remains zero when an error is
-// encountered.
+// TestAddError confirms string matrices are rejected rather than silently
+// producing a sum, and that the fallback sum is the zero value.
 func TestAddError(t *testing.T) {
-	m := &Matrix{
+	m := &Matrix[string]{
 		Data: [][]string{{"1", "two"}},
-		Size: 2,
+		Rows: 1,
+		Cols: 2,
 	}
 
 	got, err := m.Add()
 	if err == nil {
-		t.Fatalf("expected error for non-integer element; got sum %d", got)
+		t.Fatalf("expected error for string matrix; got sum %q", got)
 	}
-	if got != 0 {
-		t.Fatalf("expected zero sum on error, got %d", got)
+	if got != "" {
+		t.Fatalf("expected zero sum on error, got %q", got)
 	}
 }
 
@@ -143,8 +163,8 @@ func TestAddError(t *testing.T) {
 func TestMultiply(t *testing.T) {
 	tests := []struct {
 		name   string
-		matrix *Matrix
-		want   int
+		matrix *Matrix[int64]
+		want   int64
 	}{
 		{
 			name:   "positive values",
@@ -176,29 +196,31 @@ func TestMultiply(t *testing.T) {
 	}
 }
 
-// TestMultiplyError mirrors TestAddError to ensure non-integer elements
+// TestMultiplyError mirrors TestAddError to ensure string matrices
 // short-circuit multiplication and provide a deterministic zero result.
 func TestMultiplyError(t *testing.T) {
-	m := &Matrix{
+	m := &Matrix[string]{
 		Data: [][]string{{"foo", "2"}},
-		Size: 1,
+		Rows: 1,
+		Cols: 2,
 	}
 
 	got, err := m.Multiply()
 	if err == nil {
-		t.Fatalf("expected error for non-integer element; got product %d", got)
+		t.Fatalf("expected error for string matrix; got product %q", got)
 	}
-	if got != 0 {
-		t.Fatalf("expected zero product on error, got %d", got)
+	if got != "" {
+		t.Fatalf("expected zero product on error, got %q", got)
 	}
 }
 
 // TestEcho ensures the multi-line representation always ends with a newline
-// so handlers can stream it directly without extra formatting logic.
+// so handlers can stream it directly without extra formatting logic, and that
+// text cells are quoted as csv fields.
 func TestEcho(t *testing.T) {
 	tests := []struct {
 		name   string
-		matrix *Matrix
+		matrix *Matrix[int64]
 		want   string
 	}{
 		{
@@ -220,4 +242,352 @@ func TestEcho(t *testing.T) {
 			}
 		})
 	}
+
+	text := &Matrix[string]{Data: [][]string{{"2,5", `say "hi"`}, {"a\nb", "c"}}, Rows: 2, Cols: 2}
+	if got, want := text.Echo(), "\"2,5\",\"say \"\"hi\"\"\"\n\"a\nb\",c\n"; got != want {
+		t.Fatalf("expected text cells quoted.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+	if got, want := text.Flatten(), "\"2,5\",\"say \"\"hi\"\"\",\"a\nb\",c"; got != want {
+		t.Fatalf("expected flattened text cells quoted.\nwant: %s\ngot: %s", want, got)
+	}
+}
+
+// TestInverse checks exact rational inversion, including inputs that need a
+// row swap because the leading pivot is zero.
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix *Matrix[int64]
+		want   string
+	}{
+		{
+			name:   "identity",
+			matrix: matrixFromInts([][]int{{1, 0}, {0, 1}}),
+			want:   "1,0\n0,1\n",
+		},
+		{
+			name:   "fractional result",
+			matrix: matrixFromInts([][]int{{2, 1}, {4, 3}}),
+			want:   "3/2,-1/2\n-2,1\n",
+		},
+		{
+			name:   "zero leading pivot",
+			matrix: matrixFromInts([][]int{{0, 1, 0}, {1, 0, 0}, {0, 0, 2}}),
+			want:   "0,1,0\n1,0,0\n0,0,1/2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.matrix.Echo()
+			inv, err := tt.matrix.Inverse()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := inv.Echo(); got != tt.want {
+				t.Fatalf("inverse mismatch.\nwant:\n%s\ngot:\n%s", tt.want, got)
+			}
+			if after := tt.matrix.Echo(); after != before {
+				t.Fatalf("inverse mutated the input matrix.\nbefore:\n%s\nafter:\n%s", before, after)
+			}
+		})
+	}
+}
+
+// TestInverseErrors ensures singular matrices are reported with ErrSingular
+// and that non-numeric cells are rejected with a different error.
+func TestInverseErrors(t *testing.T) {
+	singular := matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}})
+	if _, err := singular.Inverse(); !errors.Is(err, ErrSingular) {
+		t.Fatalf("expected ErrSingular, got %v", err)
+	}
+
+	nonNumeric := &Matrix[string]{
+		Data: [][]string{{"1", "x"}, {"3", "4"}},
+		Rows: 2,
+		Cols: 2,
+	}
+	_, err := nonNumeric.Inverse()
+	if err == nil || errors.Is(err, ErrSingular) {
+		t.Fatalf("expected non-numeric error, got %v", err)
+	}
+
+	rectangular := matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}})
+	if _, err := rectangular.Inverse(); !errors.Is(err, ErrNotSquare) {
+		t.Fatalf("expected ErrNotSquare, got %v", err)
+	}
+}
+
+// TestElementTypes runs the same reductions over every numeric element type to
+// show each arithmetic is wired up and formats its result as expected.
+func TestElementTypes(t *testing.T) {
+	records := [][]string{{"1", "2"}, {"3", "4"}}
+
+	t.Run("float64", func(t *testing.T) {
+		assertReductions[float64](t, records, "10", "24")
+	})
+	t.Run("complex128", func(t *testing.T) {
+		assertReductions[complex128](t, records, "(10+0i)", "(24+0i)")
+	})
+	t.Run("rational", func(t *testing.T) {
+		assertReductions[*big.Rat](t, [][]string{{"1/2", "1/3"}, {"1/6", "2"}}, "3", "1/18")
+	})
+}
+
+// assertReductions parses records into T and checks the formatted sum and
+// product.
+func assertReductions[T Element](t *testing.T, records [][]string, wantSum, wantProd string) {
+	t.Helper()
+
+	m, err := FromRecords[T](records)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	sum, err := m.Add()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := Format(sum); got != wantSum {
+		t.Fatalf("sum mismatch: want %s got %s", wantSum, got)
+	}
+
+	prod, err := m.Multiply()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := Format(prod); got != wantProd {
+		t.Fatalf("product mismatch: want %s got %s", wantProd, got)
+	}
+}
+
+// TestOverflow ensures int64 reductions report ErrOverflow instead of
+// wrapping, while the big.Int fallback returns the exact value.
+func TestOverflow(t *testing.T) {
+	records := [][]string{{"9223372036854775807", "2"}, {"-9223372036854775808", "1"}}
+
+	ints, err := FromRecords[int64](records)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if _, err := ints.Multiply(); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow from Multiply, got %v", err)
+	}
+	if _, err := matrixFromInts([][]int{{math.MaxInt64, 1}, {0, 0}}).Add(); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow from Add, got %v", err)
+	}
+
+	bigs, err := FromRecords[*big.Int](records)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	prod, err := bigs.Multiply()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "-170141183460469231713240559642174554112"; Format(prod) != want {
+		t.Fatalf("product mismatch: want %s got %s", want, Format(prod))
+	}
+}
+
+// TestCheckedInt64 covers the edge cases of the overflow checks directly,
+// including MinInt64 * -1 which wraps to itself.
+func TestCheckedInt64(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     func(a, b int64) (int64, bool)
+		a, b   int64
+		want   int64
+		wantOK bool
+	}{
+		{"add max", addInt64, math.MaxInt64, 0, math.MaxInt64, true},
+		{"add overflow", addInt64, math.MaxInt64, 1, 0, false},
+		{"add underflow", addInt64, math.MinInt64, -1, 0, false},
+		{"mul negative", mulInt64, -4, 5, -20, true},
+		{"mul overflow", mulInt64, math.MaxInt64, 2, 0, false},
+		{"mul min by minus one", mulInt64, math.MinInt64, -1, 0, false},
+		{"mul minus one by min", mulInt64, -1, math.MinInt64, 0, false},
+		{"mul zero", mulInt64, math.MinInt64, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.op(tt.a, tt.b)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("want (%d, %v) got (%d, %v)", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+// TestMatMul checks the row-by-column product, including that it is not
+// commutative, and that mismatched inner dimensions are rejected.
+func TestMatMul(t *testing.T) {
+	a := matrixFromInts([][]int{{1, 2}, {3, 4}})
+	b := matrixFromInts([][]int{{0, 1}, {1, 0}})
+
+	ab, err := a.MatMul(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "2,1\n4,3\n"; ab.Echo() != want {
+		t.Fatalf("product mismatch.\nwant:\n%s\ngot:\n%s", want, ab.Echo())
+	}
+
+	ba, err := b.MatMul(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "3,4\n1,2\n"; ba.Echo() != want {
+		t.Fatalf("product mismatch.\nwant:\n%s\ngot:\n%s", want, ba.Echo())
+	}
+
+	rect, err := a.MatMul(matrixFromInts([][]int{{1, 0, 2}, {0, 1, 3}}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "1,2,8\n3,4,18\n"; rect.Echo() != want || rect.Rows != 2 || rect.Cols != 3 {
+		t.Fatalf("product mismatch.\nwant 2x3:\n%s\ngot %dx%d:\n%s", want, rect.Rows, rect.Cols, rect.Echo())
+	}
+
+	if _, err := a.MatMul(matrixFromInts([][]int{{1, 2}})); err == nil {
+		t.Fatalf("expected inner dimension error")
+	}
+}
+
+// TestDeterminant covers integer, fractional, singular and row-swapping input,
+// checking the Bareiss result is exact.
+func TestDeterminant(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		want    string
+	}{
+		{"1x1", [][]string{{"-7"}}, "-7"},
+		{"2x2", [][]string{{"3", "8"}, {"4", "6"}}, "-14"},
+		{"3x3", [][]string{{"6", "1", "1"}, {"4", "-2", "5"}, {"2", "8", "7"}}, "-306"},
+		{"singular", [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7", "8", "9"}}, "0"},
+		{"zero leading pivot", [][]string{{"0", "1"}, {"1", "0"}}, "-1"},
+		{"fractions", [][]string{{"1/2", "1/3"}, {"1/4", "1/5"}}, "1/60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := FromRecords[*big.Rat](tt.records)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			det, err := m.Determinant()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := det.RatString(); got != tt.want {
+				t.Fatalf("determinant mismatch: want %s got %s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := matrixFromInts([][]int{{1, 2}}).Determinant(); !errors.Is(err, ErrNotSquare) {
+		t.Fatalf("expected ErrNotSquare, got %v", err)
+	}
+}
+
+// TestTrace sums the diagonal and rejects rectangular input.
+func TestTrace(t *testing.T) {
+	got, err := matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}, {7, 8, -9}}).Trace()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != -3 {
+		t.Fatalf("trace mismatch: want -3 got %d", got)
+	}
+
+	if _, err := matrixFromInts([][]int{{1, 2}}).Trace(); !errors.Is(err, ErrNotSquare) {
+		t.Fatalf("expected ErrNotSquare, got %v", err)
+	}
+}
+
+// TestRank covers full rank, rank deficient and rectangular matrices,
+// including a zero column that forces the elimination to skip ahead.
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix *Matrix[int64]
+		want   int
+	}{
+		{"identity", matrixFromInts([][]int{{1, 0}, {0, 1}}), 2},
+		{"deficient", matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}), 2},
+		{"zeros", matrixFromInts([][]int{{0, 0}, {0, 0}}), 0},
+		{"wide", matrixFromInts([][]int{{1, 2, 3}, {2, 4, 6}}), 1},
+		{"zero column", matrixFromInts([][]int{{0, 1, 2}, {0, 2, 5}, {0, 3, 7}}), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.matrix.Rank()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("rank mismatch: want %d got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestRotate90 checks clockwise rotation of square and rectangular matrices
+// and that four rotations give back the original.
+func TestRotate90(t *testing.T) {
+	square := matrixFromInts([][]int{{1, 2}, {3, 4}})
+	if got, want := square.Rotate90().Echo(), "3,1\n4,2\n"; got != want {
+		t.Fatalf("rotate mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	rect := matrixFromInts([][]int{{1, 2, 3}, {4, 5, 6}})
+	rotated := rect.Rotate90()
+	if got, want := rotated.Echo(), "4,1\n5,2\n6,3\n"; got != want || rotated.Rows != 3 || rotated.Cols != 2 {
+		t.Fatalf("rotate mismatch.\nwant 3x2:\n%s\ngot %dx%d:\n%s", want, rotated.Rows, rotated.Cols, got)
+	}
+	if got := rotated.Rotate90().Rotate90().Rotate90().Echo(); got != rect.Echo() {
+		t.Fatalf("four rotations should be the identity, got:\n%s", got)
+	}
+}
+
+// TestStrings ensures typed matrices convert back to their csv cells.
+func TestStrings(t *testing.T) {
+	m, err := FromRecords[*big.Rat]([][]string{{"0.5", "2"}})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	s := m.Strings()
+	if want := [][]string{{"1/2", "2"}}; s.Rows != 1 || s.Cols != 2 || !reflect.DeepEqual(want, s.Data) {
+		t.Fatalf("strings mismatch: want %v got %v", want, s.Data)
+	}
+}
+
+// TestContextCancelled checks every context-aware operation stops with the
+// context's error, and that a live context gives the plain result.
+func TestContextCancelled(t *testing.T) {
+	m := matrixFromInts([][]int{{2, 1}, {1, 1}})
+	ops := map[string]func(ctx context.Context) error{
+		"add":     func(ctx context.Context) error { _, err := m.AddContext(ctx); return err },
+		"mul":     func(ctx context.Context) error { _, err := m.MultiplyContext(ctx); return err },
+		"matmul":  func(ctx context.Context) error { _, err := m.MatMulContext(ctx, m); return err },
+		"inverse": func(ctx context.Context) error { _, err := m.InverseContext(ctx); return err },
+		"det":     func(ctx context.Context) error { _, err := m.DeterminantContext(ctx); return err },
+		"rank":    func(ctx context.Context) error { _, err := m.RankContext(ctx); return err },
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			if err := op(cancelled); !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			if err := op(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
 }
@@ -0,0 +1,156 @@
+package matrix
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+	This file has the missing value policies, deciding what an empty cell means.
+	Cells are filled in as strings, before they are parsed, so every operation
+	and element type sees the same filled matrix:
+		reject     any empty cell is an error
+		zero       empty cells are 0
+		skip       empty cells are left out of reductions, eg: the identity of a sum
+		row-mean   empty cells are the mean of the rest of their row
+		col-mean   empty cells are the mean of the rest of their column
+*/
+
+// Missing is a policy for empty cells, see FillMissing.
+type Missing int
+
+const (
+	MissingKeep    Missing = iota // empty cells are left as they are, numeric operations reject them as non-numeric
+	MissingReject                 // any empty cell is a MissingCellError
+	MissingZero                   // empty cells are 0
+	MissingSkip                   // empty cells stand for the operation's skip value
+	MissingRowMean                // empty cells are the mean of their row
+	MissingColMean                // empty cells are the mean of their column
+)
+
+// missingNames are the policy names ParseMissing accepts, in Missing order.
+var missingNames = []string{"keep", "reject", "zero", "skip", "row-mean", "col-mean"}
+
+func (p Missing) String() string {
+	return missingNames[p]
+}
+
+// Returns the policy called name, eg: "row-mean". "" is MissingKeep and "na" is
+// another name for MissingSkip.
+func ParseMissing(name string) (Missing, error) {
+	switch name = strings.ToLower(name); name {
+	case "":
+		return MissingKeep, nil
+	case "na":
+		return MissingSkip, nil
+	}
+	for p, n := range missingNames {
+		if n == name {
+			return Missing(p), nil
+		}
+	}
+	return MissingKeep, fmt.Errorf("error: unknown missing value policy '%s'. must be one of %s", name, strings.Join(missingNames, ","))
+}
+
+// Returns m with its empty cells filled in per policy p, and how many there were.
+// m is never changed, it is returned as is if it has nothing to fill.
+// skip is the cell a skipped value stands for, eg: "0" in a sum or "NA" in text,
+// "" if the operation cannot skip one. Means are taken exactly over the rest of
+// the row or column, and written as floats for float64 and complex128, as exact
+// fractions otherwise. They are never rounded, a mean that is not a whole number
+// promotes an integer upload, see ParsePromoted.
+func FillMissing[T Element](m *Matrix[string], p Missing, skip string) (*Matrix[string], int, error) {
+	count := 0
+	for _, row := range m.Data {
+		for _, cell := range row {
+			if cell == "" {
+				count++
+			}
+		}
+	}
+	if count == 0 || p == MissingKeep {
+		return m, count, nil
+	}
+
+	filled := &Matrix[string]{Data: make([][]string, m.Rows), Rows: m.Rows, Cols: m.Cols, RowLabels: m.RowLabels, ColLabels: m.ColLabels}
+	rowMeans, colMeans := map[int]string{}, map[int]string{}
+	for i, row := range m.Data {
+		filled.Data[i] = row
+		if !slices.Contains(row, "") {
+			continue
+		}
+		filled.Data[i] = append([]string(nil), row...)
+		for j, cell := range row {
+			if cell != "" {
+				continue
+			}
+			var err error
+			switch p {
+			case MissingReject:
+				err = &MissingCellError{Row: i + 1, Col: j + 1}
+			case MissingZero:
+				filled.Data[i][j] = "0"
+			case MissingSkip:
+				if skip == "" {
+					err = &MissingCellError{Row: i + 1, Col: j + 1, Reason: "cannot be skipped by this operation. use missing=zero, row-mean or col-mean"}
+				}
+				filled.Data[i][j] = skip
+			case MissingRowMean:
+				filled.Data[i][j], err = memoMean[T](rowMeans, i, row, "row", i+1, j+1)
+			case MissingColMean:
+				col := make([]string, m.Rows)
+				for k := range m.Data {
+					col[k] = m.Data[k][j]
+				}
+				filled.Data[i][j], err = memoMean[T](colMeans, j, col, "col", i+1, j+1)
+			}
+			if err != nil {
+				return nil, count, err
+			}
+		}
+	}
+	return filled, count, nil
+}
+
+// Returns the mean of cells, the index-th row or column, remembered in means.
+// row and col locate the empty cell being filled, for errors.
+func memoMean[T Element](means map[int]string, index int, cells []string, kind string, row, col int) (string, error) {
+	if v, ok := means[index]; ok {
+		return v, nil
+	}
+	sum, n := new(big.Rat), 0
+	for k, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		v, err := ratArith.parse(cell)
+		if err != nil {
+			r, c := row, k+1
+			if kind == "col" {
+				r, c = k+1, col
+			}
+			return "", &NonNumericCellError{Row: r, Col: c, Value: cell, Type: ratArith.name}
+		}
+		sum.Add(sum, v)
+		n++
+	}
+	if n == 0 {
+		return "", &MissingCellError{Row: row, Col: col, Reason: fmt.Sprintf("%s %d has no values to take the mean of", kind, index+1)}
+	}
+	means[index] = formatMean[T](sum.Quo(sum, new(big.Rat).SetInt64(int64(n))))
+	return means[index], nil
+}
+
+// Writes mean as T parses it, or as a fraction for T without one.
+func formatMean[T Element](mean *big.Rat) string {
+	var zero T
+	switch any(zero).(type) {
+	case float64, complex128:
+		f, _ := mean.Float64()
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return mean.RatString()
+}
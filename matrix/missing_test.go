@@ -0,0 +1,97 @@
+package matrix
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseMissing checks policy names, aliases and the error naming the choices.
+func TestParseMissing(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]Missing{"": MissingKeep, "reject": MissingReject, "Zero": MissingZero, "na": MissingSkip, "skip": MissingSkip, "row-mean": MissingRowMean, "col-mean": MissingColMean} {
+		if got, err := ParseMissing(name); err != nil || got != want {
+			t.Fatalf("%q: expected %s, got %s (%v)", name, want, got, err)
+		}
+	}
+	if _, err := ParseMissing("mode"); err == nil || !strings.Contains(err.Error(), "must be one of keep,reject,zero,skip,row-mean,col-mean") {
+		t.Fatalf("expected unknown policy error, got %v", err)
+	}
+}
+
+// TestFillMissing checks each policy fills, or rejects, the empty cells and
+// reports how many there were.
+func TestFillMissing(t *testing.T) {
+	t.Parallel()
+
+	m := &Matrix[string]{Data: [][]string{{"1", "", "4"}, {"", "2", "6"}}, Rows: 2, Cols: 3}
+	tests := []struct {
+		name    string
+		policy  Missing
+		skip    string
+		fill    func(*Matrix[string], Missing, string) (*Matrix[string], int, error)
+		want    [][]string
+		wantErr string
+	}{
+		{name: "keep", policy: MissingKeep, fill: FillMissing[int64], want: m.Data},
+		{name: "reject", policy: MissingReject, fill: FillMissing[int64], wantErr: "missing value in matrix. row 1, col 2 is empty"},
+		{name: "zero", policy: MissingZero, fill: FillMissing[int64], want: [][]string{{"1", "0", "4"}, {"0", "2", "6"}}},
+		{name: "skip", policy: MissingSkip, skip: "1", fill: FillMissing[int64], want: [][]string{{"1", "1", "4"}, {"1", "2", "6"}}},
+		{name: "skip unsupported", policy: MissingSkip, fill: FillMissing[int64], wantErr: "row 1, col 2 is empty and cannot be skipped"},
+		{name: "row mean float", policy: MissingRowMean, fill: FillMissing[float64], want: [][]string{{"1", "2.5", "4"}, {"4", "2", "6"}}},
+		{name: "row mean exact", policy: MissingRowMean, fill: FillMissing[*big.Rat], want: [][]string{{"1", "5/2", "4"}, {"4", "2", "6"}}},
+		{name: "col mean", policy: MissingColMean, fill: FillMissing[float64], want: [][]string{{"1", "2", "4"}, {"1", "2", "6"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, count, err := tc.fill(m, tc.policy, tc.skip)
+			if count != 2 {
+				t.Fatalf("expected 2 missing cells, got %d", count)
+			}
+			if tc.wantErr != "" {
+				if !errors.Is(err, ErrMissingCell) || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.want, got.Data) {
+				t.Fatalf("want %v, got %v", tc.want, got.Data)
+			}
+		})
+	}
+
+	if m.Data[0][1] != "" || m.Data[1][0] != "" {
+		t.Fatalf("input was changed: %v", m.Data)
+	}
+}
+
+// TestFillMissingMean checks means are never rounded, even for integers, and
+// fail on a row with nothing to average or a non-numeric cell.
+func TestFillMissingMean(t *testing.T) {
+	t.Parallel()
+
+	got, _, err := FillMissing[int64](&Matrix[string]{Data: [][]string{{"-2", "-3", ""}}, Rows: 1, Cols: 3}, MissingRowMean, "")
+	if err != nil || got.Data[0][2] != "-5/2" {
+		t.Fatalf("expected the exact mean -5/2, got %v (%v)", got, err)
+	}
+
+	m := &Matrix[string]{Data: [][]string{{"-2", "-3", "1"}, {"", "", ""}, {"x", "1", "2"}}, Rows: 3, Cols: 3}
+	_, _, err = FillMissing[int64](m, MissingRowMean, "")
+	var missingErr *MissingCellError
+	if !errors.As(err, &missingErr) || *missingErr != (MissingCellError{Row: 2, Col: 1, Reason: "row 2 has no values to take the mean of"}) {
+		t.Fatalf("expected empty row error, got %v", err)
+	}
+
+	_, _, err = FillMissing[int64](m, MissingColMean, "")
+	var cellErr *NonNumericCellError
+	if !errors.As(err, &cellErr) || cellErr.Row != 3 || cellErr.Col != 1 {
+		t.Fatalf("expected non-numeric cell at row 3, col 1, got %v", err)
+	}
+}
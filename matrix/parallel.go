@@ -0,0 +1,190 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+	This file has the parallel execution mode for large matrices.
+	Rows (or column strips, for transpose) are split into chunks and handed to a
+	pool of worker goroutines. Partial results are kept per chunk and merged in
+	chunk order, so a result never depends on which worker finished first.
+	Chunks are sized by the matrix alone, never by the number of workers.
+	Matrices under parallelThreshold cells run the serial code, where the
+	goroutines would cost more than they save, and so do float matrices, whose
+	sums depend on the order they are taken in.
+*/
+
+// parallelThreshold is the number of cells below which parallel operations run serially.
+const parallelThreshold = 1 << 14
+
+// chunkCells is about how many cells a chunk of a parallel reduction holds.
+const chunkCells = 1 << 12
+
+// transposeBlock is the side of the square tiles TransposeParallel copies,
+// small enough that a tile of the source and of the result both stay in cache.
+const transposeBlock = 64
+
+// AddContext, with the rows split across workers goroutines, GOMAXPROCS if workers <= 0.
+// Partial sums are merged in row order. A partial sum overflowing, where the
+// running total might not, eg: MinInt64 in the first rows and MaxInt64 in the
+// last, is summed again cell by cell, from that chunk on.
+func (m *Matrix[T]) AddParallel(ctx context.Context, workers int) (T, error) {
+	a := arith[T]()
+	if a.add == nil || !m.parallel(workers) {
+		return m.AddContext(ctx)
+	}
+	return reduceParallel(ctx, m, workers, a.zero, a.add)
+}
+
+// MultiplyContext, with the rows split across workers goroutines, GOMAXPROCS if workers <= 0.
+// Partial products are merged in row order, and overflowing ones multiplied again
+// as in AddParallel, eg: a 0 in an earlier chunk keeps the product 0 however
+// large the later chunks are.
+func (m *Matrix[T]) MultiplyParallel(ctx context.Context, workers int) (T, error) {
+	a := arith[T]()
+	if a.mul == nil || !m.parallel(workers) {
+		return m.MultiplyContext(ctx)
+	}
+	return reduceParallel(ctx, m, workers, a.one, a.mul)
+}
+
+// Transpose, copying square tiles with the column strips split across workers
+// goroutines, GOMAXPROCS if workers <= 0. Each worker writes whole rows of the
+// result, so no two share a row. Tiling pays off even with a single worker.
+func (m *Matrix[T]) TransposeParallel(workers int) *Matrix[T] {
+	if m.Rows*m.Cols < parallelThreshold {
+		return m.Transpose()
+	}
+
+	data := make([][]T, m.Cols)
+	strips := (m.Cols + transposeBlock - 1) / transposeBlock
+	runChunks(strips, 1, poolSize(workers), func(strip, _, _ int) {
+		colLo, colHi := strip*transposeBlock, min((strip+1)*transposeBlock, m.Cols)
+		for col := colLo; col < colHi; col++ {
+			data[col] = make([]T, m.Rows)
+		}
+		for rowLo := 0; rowLo < m.Rows; rowLo += transposeBlock {
+			rowHi := min(rowLo+transposeBlock, m.Rows)
+			for row := rowLo; row < rowHi; row++ {
+				src := m.Data[row]
+				for col := colLo; col < colHi; col++ {
+					data[col][row] = src[col]
+				}
+			}
+		}
+	})
+	return &Matrix[T]{Data: data, Rows: m.Cols, Cols: m.Rows, RowLabels: m.ColLabels, ColLabels: m.RowLabels}
+}
+
+// Reports whether m is large enough, exact enough, and workers many enough, to
+// reduce in parallel.
+func (m *Matrix[T]) parallel(workers int) bool {
+	var zero T
+	switch any(zero).(type) {
+	case float64, complex128:
+		return false
+	}
+	return poolSize(workers) > 1 && m.Rows*m.Cols >= parallelThreshold
+}
+
+// Folds combine over every cell, a chunk of about chunkCells cells per task,
+// then over the partials in chunk order. From the first chunk whose partial, or
+// whose merge, overflows, the rest is folded cell by cell from the total so far,
+// returning ErrOverflow only if that running total does not fit in T.
+func reduceParallel[T Element](ctx context.Context, m *Matrix[T], workers int, start func() T, combine func(a, b T) (T, bool)) (T, error) {
+	chunkRows := max(1, chunkCells/max(1, m.Cols))
+	count := (m.Rows + chunkRows - 1) / chunkRows
+	partials := make([]T, count)
+	errs := make([]error, count)
+	var done atomic.Int64
+	var overflowed atomic.Int64 // the first chunk known to overflow, later ones are never merged
+	overflowed.Store(int64(count))
+
+	runChunks(m.Rows, chunkRows, poolSize(workers), func(chunk, lo, hi int) {
+		acc := start()
+		for _, row := range m.Data[lo:hi] {
+			if int64(chunk) > overflowed.Load() {
+				return
+			}
+			if err := checkpoint(ctx, int(done.Add(1))-1, m.Rows); err != nil {
+				errs[chunk] = err
+				return
+			}
+			for _, v := range row {
+				var ok bool
+				if acc, ok = combine(acc, v); !ok {
+					errs[chunk] = ErrOverflow
+					for first := overflowed.Load(); int64(chunk) < first && !overflowed.CompareAndSwap(first, int64(chunk)); first = overflowed.Load() {
+					}
+					return
+				}
+			}
+		}
+		partials[chunk] = acc
+	})
+
+	acc := start()
+	for chunk, partial := range partials {
+		switch {
+		case errs[chunk] == nil:
+			if next, ok := combine(acc, partial); ok {
+				acc = next
+				continue
+			}
+		case !errors.Is(errs[chunk], ErrOverflow):
+			return start(), errs[chunk]
+		}
+		return foldFrom(ctx, m.Data[chunk*chunkRows:], acc, start, combine)
+	}
+	return acc, nil
+}
+
+// Folds combine over every cell of rows, starting from acc, as the serial
+// operations do.
+func foldFrom[T Element](ctx context.Context, rows [][]T, acc T, start func() T, combine func(a, b T) (T, bool)) (T, error) {
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return start(), err
+		}
+		for _, v := range row {
+			var ok bool
+			if acc, ok = combine(acc, v); !ok {
+				return start(), ErrOverflow
+			}
+		}
+	}
+	return acc, nil
+}
+
+// Splits [0, n) into contiguous chunks of size, the last maybe shorter, and runs
+// fn over each on a pool of workers goroutines, returning once every chunk is
+// done. Chunks are taken in order. fn gets the chunk's index so results can be
+// stored per chunk.
+func runChunks(n, size, workers int, fn func(chunk, lo, hi int)) {
+	count := (n + size - 1) / size
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(workers, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := int(next.Add(1)) - 1; chunk < count; chunk = int(next.Add(1)) - 1 {
+				fn(chunk, chunk*size, min((chunk+1)*size, n))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Returns workers, or GOMAXPROCS if workers <= 0.
+func poolSize(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
@@ -0,0 +1,217 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// sequence returns a rows x cols matrix counting up from 1, so every cell differs.
+func sequence(rows, cols int) *Matrix[int64] {
+	data := make([][]int64, rows)
+	for i := range data {
+		data[i] = make([]int64, cols)
+		for j := range data[i] {
+			data[i][j] = int64(i*cols + j + 1)
+		}
+	}
+	return &Matrix[int64]{Data: data, Rows: rows, Cols: cols}
+}
+
+// floats returns a rows x cols matrix of values near 1, whose product stays finite
+// but depends on the order it is taken in.
+func floats(rows, cols int) *Matrix[float64] {
+	data := make([][]float64, rows)
+	for i := range data {
+		data[i] = make([]float64, cols)
+		for j := range data[i] {
+			data[i][j] = 1 + float64((i*cols+j)%7-3)/1e4
+		}
+	}
+	return &Matrix[float64]{Data: data, Rows: rows, Cols: cols}
+}
+
+// TestAddParallel checks the parallel sum matches the serial one for any
+// number of workers, on matrices over and under the parallel threshold.
+func TestAddParallel(t *testing.T) {
+	t.Parallel()
+
+	for _, m := range []*Matrix[int64]{sequence(301, 157), sequence(3, 3), sequence(1, 20000)} {
+		want, err := m.Add()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, workers := range []int{0, 1, 2, 3, 8, 1000} {
+			got, err := m.AddParallel(context.Background(), workers)
+			if err != nil || got != want {
+				t.Fatalf("%dx%d with %d workers: want %d got %d (%v)", m.Rows, m.Cols, workers, want, got, err)
+			}
+		}
+	}
+}
+
+// TestMultiplyParallel checks products match the serial one for any number of
+// workers, floats included, whose products depend on the order they are taken in.
+func TestMultiplyParallel(t *testing.T) {
+	t.Parallel()
+
+	signs := sequence(400, 200)
+	for _, row := range signs.Data {
+		for j, v := range row {
+			row[j] = 1 - 2*(v%3%2)
+		}
+	}
+	m := floats(400, 200)
+	wantInt, _ := signs.Multiply()
+	want, _ := m.Multiply()
+	for _, workers := range []int{0, 1, 2, 3, 8} {
+		if got, err := signs.MultiplyParallel(context.Background(), workers); err != nil || got != wantInt {
+			t.Fatalf("%d workers: want %d got %d (%v)", workers, wantInt, got, err)
+		}
+		if got, err := m.MultiplyParallel(context.Background(), workers); err != nil || got != want {
+			t.Fatalf("%d workers: want %v got %v (%v)", workers, want, got, err)
+		}
+	}
+}
+
+// TestParallelErrors checks overflow, non-numeric matrices and cancellation
+// are reported as by the serial operations.
+func TestParallelErrors(t *testing.T) {
+	t.Parallel()
+
+	huge := sequence(200, 100)
+	huge.Data[150][50] = math.MaxInt64
+	if _, err := huge.AddParallel(context.Background(), 4); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow from add, got %v", err)
+	}
+	if _, err := sequence(200, 100).MultiplyParallel(context.Background(), 4); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow from multiply, got %v", err)
+	}
+
+	// a partial overflowing is no overflow if the running total never does
+	cancels := &Matrix[int64]{Data: make([][]int64, 128), Rows: 128, Cols: 128}
+	for i := range cancels.Data {
+		cancels.Data[i] = make([]int64, 128)
+	}
+	cancels.Data[0][0] = math.MinInt64
+	cancels.Data[127][126], cancels.Data[127][127] = math.MaxInt64, math.MaxInt64
+	if got, err := cancels.AddParallel(context.Background(), 4); err != nil || got != math.MaxInt64-1 {
+		t.Fatalf("expected %d from add, got %d (%v)", int64(math.MaxInt64-1), got, err)
+	}
+	zeroed := sequence(128, 128)
+	for _, row := range zeroed.Data {
+		for j := range row {
+			row[j] = 2
+		}
+	}
+	zeroed.Data[0][0] = 0
+	if got, err := zeroed.MultiplyParallel(context.Background(), 4); err != nil || got != 0 {
+		t.Fatalf("expected 0 from multiply, got %d (%v)", got, err)
+	}
+
+	text := &Matrix[string]{Data: make([][]string, 200), Rows: 200, Cols: 100}
+	for i := range text.Data {
+		text.Data[i] = make([]string, 100)
+	}
+	if _, err := text.AddParallel(context.Background(), 4); err == nil {
+		t.Fatalf("expected non-numeric error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sequence(200, 100).AddParallel(ctx, 4); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestTransposeParallel checks the blocked transpose matches Transpose, labels
+// included, for shapes that are not multiples of the block size.
+func TestTransposeParallel(t *testing.T) {
+	t.Parallel()
+
+	labelled := sequence(150, 120)
+	for i := range labelled.Rows {
+		labelled.RowLabels = append(labelled.RowLabels, fmt.Sprint("r", i))
+	}
+	for j := range labelled.Cols {
+		labelled.ColLabels = append(labelled.ColLabels, fmt.Sprint("c", j))
+	}
+
+	for _, m := range []*Matrix[int64]{sequence(301, 157), sequence(1, 20000), sequence(20000, 1), sequence(2, 3), labelled} {
+		for _, workers := range []int{0, 1, 3} {
+			got, want := m.TransposeParallel(workers), m.Transpose()
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%dx%d with %d workers: transpose mismatch", m.Rows, m.Cols, workers)
+			}
+		}
+	}
+}
+
+// Compare serial and parallel runs with eg: go test ./matrix -bench . -cpu 1,4,8
+// Parallel uses GOMAXPROCS workers, so with -cpu 1 the reductions run the serial
+// code while transpose still gains from tiling.
+
+func BenchmarkAdd(b *testing.B) {
+	m := sequence(2048, 2048)
+	b.Run("serial", func(b *testing.B) {
+		for b.Loop() {
+			m.Add()
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for b.Loop() {
+			m.AddParallel(context.Background(), 0)
+		}
+	})
+}
+
+func BenchmarkMultiply(b *testing.B) {
+	m := sequence(2048, 2048)
+	for _, row := range m.Data {
+		for j, v := range row {
+			row[j] = 1 - 2*(v%2)
+		}
+	}
+	b.Run("serial", func(b *testing.B) {
+		for b.Loop() {
+			m.Multiply()
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for b.Loop() {
+			m.MultiplyParallel(context.Background(), 0)
+		}
+	})
+
+	// most int64 products overflow, within a few cells of every chunk
+	overflows := sequence(2048, 2048)
+	b.Run("overflow/serial", func(b *testing.B) {
+		for b.Loop() {
+			overflows.Multiply()
+		}
+	})
+	b.Run("overflow/parallel", func(b *testing.B) {
+		for b.Loop() {
+			overflows.MultiplyParallel(context.Background(), 0)
+		}
+	})
+}
+
+func BenchmarkTranspose(b *testing.B) {
+	for _, n := range []int{512, 2048} {
+		m := sequence(n, n)
+		b.Run(fmt.Sprintf("serial/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				m.Transpose()
+			}
+		})
+		b.Run(fmt.Sprintf("parallel/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				m.TransposeParallel(0)
+			}
+		})
+	}
+}
@@ -1,66 +1,245 @@
 package matrix
 
 import (
-	"encoding/csv"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strings"
 )
 
 /*
 	This file has ELT Operations:
-	- Extracts file from Http.Request
-	- Extracts matrix form file
+	- Extracts file from Http.Request, or rows from a JSON body
+	- Extracts matrix form file, decompressing it and streaming it row by row within Limits
 	- Sanitizes the retrieved matrix
-	- Loads into Matrix struct
+	- Parses cells and loads into Matrix struct
 */
 
-// Extracts file from http.request and returns valid Matrix
-func NewMatrix(r *http.Request) (*Matrix, error) {
+// Limits bounds the size of an uploaded matrix, zero fields are unlimited.
+// Form uploads are checked row by row as they are read, so an oversized
+// upload is rejected without reading the rest of it.
+type Limits struct {
+	MaxRows         int
+	MaxCols         int
+	MaxCellLen      int   // in bytes
+	MaxDecompressed int64 // bytes a compressed upload may expand to
+}
 
-	// read from file
-	const keyName string = "file"
-	file, _, err := r.FormFile(keyName)
+// Returns a LimitError if record, the n-th row (1-based), breaks a limit.
+func (l Limits) CheckRow(n int, record []string) error {
+	if l.MaxRows > 0 && n > l.MaxRows {
+		return &LimitError{Limit: "rows", Max: l.MaxRows, Got: n, Row: n}
+	}
+	if l.MaxCols > 0 && len(record) > l.MaxCols {
+		return &LimitError{Limit: "cols", Max: l.MaxCols, Got: len(record), Row: n}
+	}
+	if l.MaxCellLen > 0 {
+		for j, cell := range record {
+			if len(cell) > l.MaxCellLen {
+				return &LimitError{Limit: "cell", Max: l.MaxCellLen, Got: len(cell), Row: n, Col: j + 1}
+			}
+		}
+	}
+	return nil
+}
+
+// Extracts file from http.request and returns valid Matrix.
+// Cells are parsed into T once here, so operations never re-parse strings.
+func NewMatrix[T Element](r *http.Request) (*Matrix[T], error) {
+	return NewMatrixFromForm[T](r, "file")
+}
+
+// Extracts the form file uploaded under keyName and returns valid Matrix.
+func NewMatrixFromForm[T Element](r *http.Request, keyName string) (*Matrix[T], error) {
+	in, err := NewMatricesFromForm[T](r, []string{keyName}, Dialect{}, Limits{})
+	if err != nil {
+		return nil, err
+	}
+	return in[0], nil
+}
+
+// Finds the form file uploaded under keyName and returns a Scanner over it, for
+// operations that work row by row. Parts before it are skipped unread.
+func NewScannerFromForm(r *http.Request, keyName string, d Dialect, limits Limits) (*Scanner, error) {
+	parts, err := r.MultipartReader()
 	if err != nil {
 		return nil, fmt.Errorf("error: %s. must upload form file with key '%s'", err.Error(), keyName)
 	}
-	defer file.Close()
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error: http: no such file. must upload form file with key '%s'", keyName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error: %w", err)
+		}
+		if part.FormName() == keyName {
+			rd, err := Decompress(part, limits)
+			if err != nil {
+				return nil, err
+			}
+			return NewScanner(rd, d, limits), nil
+		}
+	}
+}
 
-	// use use csv reader to return records
-	records, err := csv.NewReader(file).ReadAll()
+// Extracts one form file per key, in keys order, and returns valid Matrices.
+// The multipart body is streamed part by part, never buffered whole, and each
+// csv is checked against limits as it is read. Compressed files are
+// decompressed, see Decompress, and read in dialect d. Parts may arrive in any
+// order, parts under other keys are skipped. Reads the request body, so call it once.
+func NewMatricesFromForm[T Element](r *http.Request, keys []string, d Dialect, limits Limits) ([]*Matrix[T], error) {
+	parts, err := r.MultipartReader()
 	if err != nil {
-		return nil, fmt.Errorf("error: %s", err.Error())
+		return nil, fmt.Errorf("error: %s. must upload form file with key '%s'", err.Error(), keys[0])
+	}
+
+	found := make([]*Matrix[T], len(keys))
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error: %w", err)
+		}
+
+		i := slices.Index(keys, part.FormName())
+		if i < 0 || found[i] != nil {
+			continue
+		}
+		rd, err := Decompress(part, limits)
+		if err != nil {
+			return nil, err
+		}
+		if found[i], err = ReadMatrix[T](rd, d, limits); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, m := range found {
+		if m == nil {
+			return nil, fmt.Errorf("error: http: no such file. must upload form file with key '%s'", keys[i])
+		}
 	}
+	return found, nil
+}
+
+// Reads csv records written in dialect d one at a time, stopping at the first
+// row breaking limits. Cells are trimmed, see cleanMatrix.
+func ReadCSV(r io.Reader, d Dialect, limits Limits) ([][]string, error) {
+	s := NewScanner(r, d, limits)
+	var records [][]string
+	for s.Scan() {
+		records = append(records, slices.Clone(s.Row()))
+	}
+	return records, s.Err()
+}
+
+// Reads a csv matrix written in dialect d, within limits, and parses every cell
+// into T. The header row and label column, if d has them, become its labels.
+func ReadMatrix[T Element](r io.Reader, d Dialect, limits Limits) (*Matrix[T], error) {
+	s := NewScanner(r, d, limits)
+	var records [][]string
+	var labels []string
+	for s.Scan() {
+		records = append(records, slices.Clone(s.Row()))
+		if d.Labels {
+			labels = append(labels, s.Label())
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	m, err := FromRecords[T](records)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.setLabels(labels, s.Header()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Parses a JSON array of rows, eg: [[1,2],[3,4]], into a valid Matrix.
+// Cells may be JSON numbers or strings, null is an empty cell.
+// Numbers keep their literal text, so "1.50" or 1e3 parse exactly as in csv.
+func FromJSON[T Element](data []byte) (*Matrix[T], error) {
+	var rows [][]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("error: matrix must be a JSON array of rows. %w", err)
+	}
+
+	records := make([][]string, len(rows))
+	for i, row := range rows {
+		records[i] = make([]string, len(row))
+		for j, cell := range row {
+			switch v := cell.(type) {
+			case json.Number:
+				records[i][j] = v.String()
+			case string:
+				records[i][j] = v
+			case nil:
+				records[i][j] = ""
+			default:
+				return nil, fmt.Errorf("error: row %d, col %d must be a number or string, got %v", i+1, j+1, v)
+			}
+		}
+	}
+
+	cleanMatrix(records)
+	return FromRecords[T](records)
+}
+
+// Validates raw csv records and parses every cell into T.
+// Parse errors report the 1-based row and column of the offending cell.
+func FromRecords[T Element](records [][]string) (*Matrix[T], error) {
 
 	// csv.ReadAll() returns valid on empty file, check for empty records
 	if len(records) == 0 {
-		return nil, fmt.Errorf("error: empty matrix")
+		return nil, ErrEmptyMatrix
 	}
 
-	// Validate matrix for NxN
-	if err = validateNxN(records); err != nil {
+	// Validate matrix is rectangular, squareness is up to each operation
+	if err := validateShape(records); err != nil {
 		return nil, err
 	}
 
-	// Call to sanitize matrix
-	cleanMatrix(records)
+	a := arith[T]()
+	data := make([][]T, len(records))
+	for i, row := range records {
+		data[i] = make([]T, len(row))
+		for j, cell := range row {
+			v, err := a.parse(cell)
+			if err != nil {
+				return nil, &NonNumericCellError{Row: i + 1, Col: j + 1, Value: cell, Type: a.name}
+			}
+			data[i][j] = v
+		}
+	}
 
 	// Initialize matrix
-	matrix := &Matrix{
-		Data: records,
-		Size: len(records),
+	matrix := &Matrix[T]{
+		Data: data,
+		Rows: len(records),
+		Cols: len(records[0]),
 	}
 
 	return matrix, nil
 }
 
-// Strictly validates on NxN-ness of matrix
-// A matrix is a valid NxN if number of rows == number of columns per row
-func validateNxN(records [][]string) error {
-	// number of #rows must == #cols(/#elements in each row)
-	for _, row := range records {
-		if len(row) != len(records) {
-			return fmt.Errorf("error: not an NxN matrix")
+// Validates the records form a MxN matrix
+// A matrix is a valid MxN if every row has as many columns as the first
+func validateShape(records [][]string) error {
+	for i, row := range records {
+		if len(row) != len(records[0]) {
+			return fmt.Errorf("error: not a MxN matrix. row %d has %d columns, expected %d", i+1, len(row), len(records[0]))
 		}
 	}
 	return nil
@@ -68,15 +247,17 @@ func validateNxN(records [][]string) error {
 
 // Sanitize matrix as desired.
 // Trims spaces in each element.
-// Commented out code to optionally, replace empty cells with "NA"
 func cleanMatrix(records [][]string) {
-	// trim spaces on each element. avoid conversion failures downstream
 	for _, row := range records {
-		for i, elem := range row {
-			row[i] = strings.Trim(elem, " ")
-			// if row[i] == "" {
-			// 	row[i] = "NA"
-			// }
-		}
+		cleanRow(row)
+	}
+}
+
+// Sanitizes a single row, in place.
+// Empty cells are left empty, FillMissing decides what they mean.
+func cleanRow(row []string) {
+	// trim spaces on each element. avoid conversion failures downstream
+	for i, elem := range row {
+		row[i] = strings.Trim(elem, " ")
 	}
 }
@@ -2,7 +2,9 @@ package matrix
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"math/big"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -43,21 +45,24 @@ func TestNewMatrix(t *testing.T) {
 		includeFile bool
 		contents    string
 		wantErr     string
-		wantSize    int
+		wantRows    int
+		wantCols    int
 		wantData    [][]string
 	}{
 		{
 			name:        "valid 2x2 matrix",
 			includeFile: true,
 			contents:    "1,2\n3,4\n",
-			wantSize:    2,
+			wantRows:    2,
+			wantCols:    2,
 			wantData:    [][]string{{"1", "2"}, {"3", "4"}},
 		},
 		{
 			name:        "ints with spaces",
 			includeFile: true,
 			contents:    " 1,2 \n3 , 4\n",
-			wantSize:    2,
+			wantRows:    2,
+			wantCols:    2,
 			wantData:    [][]string{{"1", "2"}, {"3", "4"}},
 		},
 		{
@@ -70,7 +75,8 @@ func TestNewMatrix(t *testing.T) {
 			name:        "empty cells",
 			includeFile: true,
 			contents:    " 1,,3\n4,5,6\n,8,9\n",
-			wantSize:    3,
+			wantRows:    3,
+			wantCols:    3,
 			wantData:    [][]string{{"1", "", "3"}, {"4", "5", "6"}, {"", "8", "9"}},
 		},
 		{
@@ -79,10 +85,12 @@ func TestNewMatrix(t *testing.T) {
 			wantErr:     "must upload form file",
 		},
 		{
-			name:        "non square",
+			name:        "rectangular",
 			includeFile: true,
 			contents:    "1,2,3\n4,5,6\n",
-			wantErr:     "not an NxN matrix",
+			wantRows:    2,
+			wantCols:    3,
+			wantData:    [][]string{{"1", "2", "3"}, {"4", "5", "6"}},
 		},
 		{
 			name:        "empty file",
@@ -103,7 +111,7 @@ func TestNewMatrix(t *testing.T) {
 			t.Parallel()
 
 			req := buildMultipartRequest(tc.includeFile, tc.contents)
-			m, err := NewMatrix(req)
+			m, err := NewMatrix[string](req)
 
 			if tc.wantErr != "" {
 				if err == nil {
@@ -122,8 +130,8 @@ func TestNewMatrix(t *testing.T) {
 				t.Fatalf("unexpected error: %v", err)
 			}
 
-			if m.Size != tc.wantSize {
-				t.Fatalf("expected size %d, got %d", tc.wantSize, m.Size)
+			if m.Rows != tc.wantRows || m.Cols != tc.wantCols {
+				t.Fatalf("expected %dx%d, got %dx%d", tc.wantRows, tc.wantCols, m.Rows, m.Cols)
 			}
 
 			if !reflect.DeepEqual(tc.wantData, m.Data) {
@@ -133,7 +141,113 @@ func TestNewMatrix(t *testing.T) {
 	}
 }
 
-func TestValidateNxN(t *testing.T) {
+// TestFromRecordsTyped checks cells are parsed into the requested element type
+// and that parse failures point at the offending cell.
+func TestFromRecordsTyped(t *testing.T) {
+	t.Parallel()
+
+	m, err := FromRecords[int64]([][]string{{"1", "-2"}, {"3", "4"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := [][]int64{{1, -2}, {3, 4}}; !reflect.DeepEqual(want, m.Data) {
+		t.Fatalf("matrix data mismatch. want %#v, got %#v", want, m.Data)
+	}
+
+	_, err = FromRecords[int64]([][]string{{"1", "2"}, {"3", "4.5"}})
+	if err == nil {
+		t.Fatalf("expected error for float cell in int matrix")
+	}
+	if want := `non-int values in matrix. row 2, col 2 has "4.5"`; !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error containing %q, got %q", want, err.Error())
+	}
+
+	if _, err = FromRecords[float64]([][]string{{"1", "2"}, {"3", "4.5"}}); err != nil {
+		t.Fatalf("unexpected error parsing floats: %v", err)
+	}
+
+	// fractions and decimals are read in base 10 whatever their leading digits
+	rats, err := FromRecords[*big.Rat]([][]string{{"010/1", "-1/3", "1.5", "2e3", "007"}})
+	if err != nil {
+		t.Fatalf("unexpected error parsing rationals: %v", err)
+	}
+	for j, want := range []string{"10", "-1/3", "3/2", "2000", "7"} {
+		if got := rats.Data[0][j].RatString(); got != want {
+			t.Fatalf("col %d: want %s, got %s", j+1, want, got)
+		}
+	}
+	for _, cell := range []string{"0x10/1", "0x10", "0b1", "1/0", "1/-3", "1/+3", "1_000"} {
+		if _, err := FromRecords[*big.Rat]([][]string{{cell}}); err == nil {
+			t.Fatalf("expected %q to be rejected", cell)
+		}
+	}
+}
+
+// TestFromJSON checks JSON rows are accepted as numbers, strings or null and
+// that malformed bodies and non-scalar cells are rejected.
+func TestFromJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantErr  string
+		wantData [][]string
+	}{
+		{
+			name:     "numbers",
+			body:     `[[1, 2.50], [-3, 4e2]]`,
+			wantData: [][]string{{"1", "2.50"}, {"-3", "4e2"}},
+		},
+		{
+			name:     "strings and null",
+			body:     `[[" a ", null], ["b", "c"]]`,
+			wantData: [][]string{{"a", ""}, {"b", "c"}},
+		},
+		{
+			name:    "empty",
+			body:    `[]`,
+			wantErr: "empty matrix",
+		},
+		{
+			name:    "ragged",
+			body:    `[[1, 2], [3]]`,
+			wantErr: "row 2 has 1 columns",
+		},
+		{
+			name:    "nested cell",
+			body:    `[[1, [2]], [3, 4]]`,
+			wantErr: "row 1, col 2 must be a number or string",
+		},
+		{
+			name:    "not an array",
+			body:    `{"matrix": 1}`,
+			wantErr: "must be a JSON array of rows",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := FromJSON[string]([]byte(tc.body))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.wantData, m.Data) {
+				t.Fatalf("matrix data mismatch. want %#v, got %#v", tc.wantData, m.Data)
+			}
+		})
+	}
+}
+
+func TestValidateShape(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
@@ -146,9 +260,13 @@ func TestValidateNxN(t *testing.T) {
 			records: [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7", "8", "9"}},
 		},
 		{
-			name:    "non square",
+			name:    "rectangular 1x3",
+			records: [][]string{{"1", "2", "3"}},
+		},
+		{
+			name:    "ragged",
 			records: [][]string{{"1", "2"}, {"3"}},
-			wantErr: "not an NxN matrix",
+			wantErr: "row 2 has 1 columns, expected 2",
 		},
 	}
 
@@ -156,7 +274,7 @@ func TestValidateNxN(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			err := validateNxN(tc.records)
+			err := validateShape(tc.records)
 			if tc.wantErr == "" && err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -171,3 +289,81 @@ func TestValidateNxN(t *testing.T) {
 		})
 	}
 }
+
+// failingReader errors if read, standing in for the unread rest of an upload.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read past the limit")
+}
+
+// TestReadCSVLimits checks each limit is enforced with the offending row and
+// cell, and that reading stops at the first row over a limit.
+func TestReadCSVLimits(t *testing.T) {
+	t.Parallel()
+
+	limits := Limits{MaxRows: 2, MaxCols: 3, MaxCellLen: 4}
+	tests := []struct {
+		name  string
+		input io.Reader
+		want  *LimitError
+	}{
+		{name: "within limits", input: strings.NewReader("1,2,3\n4,5,6\n")},
+		{name: "rows", input: io.MultiReader(strings.NewReader("1\n2\n3\n"), failingReader{}), want: &LimitError{Limit: "rows", Max: 2, Got: 3, Row: 3}},
+		{name: "cols", input: io.MultiReader(strings.NewReader("1,2,3,4\n"), failingReader{}), want: &LimitError{Limit: "cols", Max: 3, Got: 4, Row: 1}},
+		{name: "cell", input: strings.NewReader("1,2\n3,12345\n"), want: &LimitError{Limit: "cell", Max: 4, Got: 5, Row: 2, Col: 2}},
+		{name: "cell before trim", input: strings.NewReader("1,     2\n"), want: &LimitError{Limit: "cell", Max: 4, Got: 6, Row: 1, Col: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadCSV(tt.input, Dialect{}, limits)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var got *LimitError
+			if !errors.As(err, &got) || !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("expected LimitError, got %v", err)
+			}
+			if *got != *tt.want {
+				t.Fatalf("limit mismatch: want %+v got %+v", *tt.want, *got)
+			}
+		})
+	}
+}
+
+// TestNewMatricesFromForm checks uploads are matched to keys whatever order
+// the parts arrive in, and that a missing key is named.
+func TestNewMatricesFromForm(t *testing.T) {
+	t.Parallel()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, part := range [][2]string{{"b", "3,4\n"}, {"other", "x\n"}, {"a", "1,2\n"}} {
+		w, _ := writer.CreateFormFile(part[0], part[0]+".csv")
+		io.WriteString(w, part[1])
+	}
+	writer.Close()
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body.Bytes()))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	in, err := NewMatricesFromForm[int64](newRequest(), []string{"a", "b"}, Dialect{}, Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := in[0].Echo() + in[1].Echo(); got != "1,2\n3,4\n" {
+		t.Fatalf("expected a then b, got %q", got)
+	}
+
+	_, err = NewMatricesFromForm[int64](newRequest(), []string{"a", "c"}, Dialect{}, Limits{})
+	if err == nil || !strings.Contains(err.Error(), "must upload form file with key 'c'") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package matrix
+
+import "context"
+
+/*
+	This file has progress reporting for the context-aware operations.
+	Where they check ctx, once per row (or per column while eliminating), they
+	also report how far they are to a func set on ctx with WithProgress.
+*/
+
+type progressKey struct{}
+
+// Returns a copy of ctx under which the ...Context and ...Parallel operations call
+// fn with the steps done so far out of total. A step is a row, or a column for
+// Inverse, Determinant and Rank. Parallel operations call fn from several goroutines.
+func WithProgress(ctx context.Context, fn func(done, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// Reports done out of total to the func set with WithProgress, if any, and
+// returns ctx.Err().
+func checkpoint(ctx context.Context, done, total int) error {
+	if fn, ok := ctx.Value(progressKey{}).(func(done, total int)); ok {
+		fn(done, total)
+	}
+	return ctx.Err()
+}
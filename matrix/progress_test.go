@@ -0,0 +1,48 @@
+package matrix
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestWithProgress checks operations report each step as they reach it, and
+// that parallel operations report every row once.
+func TestWithProgress(t *testing.T) {
+	t.Parallel()
+
+	var steps []int
+	ctx := WithProgress(context.Background(), func(done, total int) {
+		if total != 3 {
+			t.Errorf("expected a total of 3, got %d", total)
+		}
+		steps = append(steps, done)
+	})
+
+	m := matrixFromInts([][]int{{2, 0, 0}, {0, 3, 0}, {0, 0, 4}})
+	if _, err := m.AddContext(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.InverseContext(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{0, 1, 2, 0, 1, 2}; !reflect.DeepEqual(steps, want) {
+		t.Fatalf("progress mismatch: want %v got %v", want, steps)
+	}
+
+	var mu sync.Mutex
+	seen := map[int]bool{}
+	big := sequence(200, 100)
+	ctx = WithProgress(context.Background(), func(done, total int) {
+		mu.Lock()
+		seen[done] = true
+		mu.Unlock()
+	})
+	if _, err := big.AddParallel(ctx, 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != big.Rows || !seen[0] || !seen[big.Rows-1] {
+		t.Fatalf("expected every row reported once, got %d of %d", len(seen), big.Rows)
+	}
+}
@@ -0,0 +1,152 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+/*
+	This file has type promotion for arithmetic over uploads that are mostly,
+	but not all, integers. A cell I cannot parse but F can, eg: 1.5 or 2e3 with
+	I int64 and F float64, promotes the whole upload to F. In memory the cells
+	are parsed as I until such a cell, see ParsePromoted. Streamed, the running result is
+	carried over into F at the first such cell.
+	An integer cell too large for I, eg: 2^63 for int64, does not promote the
+	upload: it is an overflow, as a sum growing past I would be.
+*/
+
+// Parses every cell of in as I, in a single pass, unless a cell I cannot parse
+// but F can, eg: 1.5 with I int64 and F float64, or 1/2 with I *big.Int and F
+// *big.Rat. That cell promotes in, and every cell is parsed again as F.
+// Returns the matrices as I, or as F if promoted, the other is nil. A cell
+// neither can parse is a NonNumericCellError for I. An integer cell too large
+// for I is ErrOverflow, unless another cell promotes in.
+func ParsePromoted[I, F Number](in ...*Matrix[string]) ([]*Matrix[I], []*Matrix[F], error) {
+	narrow := make([]*Matrix[I], len(in))
+	for i, m := range in {
+		p, err := FromRecords[I](m.Data)
+		var cellErr *NonNumericCellError
+		if errors.As(err, &cellErr) {
+			if _, ferr := arith[F]().parse(cellErr.Value); ferr == nil {
+				wide, err := parseAs[F](in)
+				if err == nil && tooLarge[I](cellErr.Value) && !promotes[I](in) {
+					return nil, nil, ErrOverflow
+				}
+				return nil, wide, err
+			}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		narrow[i] = p
+	}
+	return narrow, nil, nil
+}
+
+// Reports whether cell is an integer too large for I, eg: 2^63 for int64.
+func tooLarge[I Number](cell string) bool {
+	_, err := arith[I]().parse(cell)
+	return errors.Is(err, strconv.ErrRange)
+}
+
+// Reports whether a cell of in is a number I cannot parse, other than an
+// integer too large for it. Every cell must be a number.
+func promotes[I Number](in []*Matrix[string]) bool {
+	for _, m := range in {
+		for _, row := range m.Data {
+			for _, cell := range row {
+				if _, err := arith[I]().parse(cell); err != nil && !errors.Is(err, strconv.ErrRange) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Parses every cell of in as T.
+func parseAs[T Element](in []*Matrix[string]) ([]*Matrix[T], error) {
+	parsed := make([]*Matrix[T], len(in))
+	for i, m := range in {
+		p, err := FromRecords[T](m.Data)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = p
+	}
+	return parsed, nil
+}
+
+// Returns the sum of every cell as ScanAdd[I] would, or as ScanAdd[F] would if
+// a cell promotes the upload, see ParsePromoted. The sum is formatted as the type it
+// ended in. An int64 sum overflowing, or a cell too large for int64, is ErrOverflow
+// unless another cell promotes the upload.
+func ScanAddPromoted[I, F Number](ctx context.Context, s *Scanner) (string, error) {
+	return scanPromoted(ctx, s, arith[I]().zero(), arith[I]().add, arith[F]().add)
+}
+
+// Returns the product of every cell as ScanMultiply[I] would, or as
+// ScanMultiply[F] would if a cell promotes the upload, see ScanAddPromoted.
+func ScanMultiplyPromoted[I, F Number](ctx context.Context, s *Scanner) (string, error) {
+	return scanPromoted(ctx, s, arith[I]().one(), arith[I]().mul, arith[F]().mul)
+}
+
+// Folds combine over every cell as I, starting from acc, until a cell promotes
+// the upload or overflows I. From there on the fold carries on in F, from acc
+// converted to F, until it overflows F too. Cells are parsed to the end either
+// way, so a bad cell is reported as by scanReduce.
+func scanPromoted[I, F Number](ctx context.Context, s *Scanner, acc I, combine func(a, b I) (I, bool), widen func(a, b F) (F, bool)) (string, error) {
+	ai, af := arith[I](), arith[F]()
+	var wide F
+	widened, promoted, overflow, wideOverflow := false, false, false, false
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		for j, cell := range s.Row() {
+			if !widened {
+				v, err := ai.parse(cell)
+				if err == nil {
+					if next, ok := combine(acc, v); ok {
+						acc = next
+						continue
+					}
+					overflow = true
+				} else if _, err := af.parse(cell); err != nil {
+					return "", &NonNumericCellError{Row: s.Rows(), Col: j + 1, Value: cell, Type: ai.name}
+				}
+				// acc still holds the result before this cell, exact as text
+				wide, _ = af.parse(ai.format(acc))
+				widened = true
+			}
+
+			v, err := af.parse(cell)
+			if err != nil {
+				return "", &NonNumericCellError{Row: s.Rows(), Col: j + 1, Value: cell, Type: af.name}
+			}
+			if !promoted {
+				_, err := ai.parse(cell)
+				overflow = overflow || errors.Is(err, strconv.ErrRange)
+				promoted = err != nil && !errors.Is(err, strconv.ErrRange)
+			}
+			if !wideOverflow {
+				var ok bool
+				wide, ok = widen(wide, v)
+				wideOverflow = !ok
+			}
+		}
+	}
+	if err := s.end(); err != nil {
+		return "", err
+	}
+	switch {
+	case wideOverflow:
+		return "", ErrOverflow
+	case promoted:
+		return af.format(wide), nil
+	case overflow:
+		return "", ErrOverflow
+	}
+	return ai.format(acc), nil
+}
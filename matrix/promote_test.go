@@ -0,0 +1,123 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestParsePromoted checks which cells promote integer arithmetic, that the
+// result comes back in exactly one type, and which type a bad cell is named for.
+func TestParsePromoted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cells     []string
+		wantFloat bool   // promoted to float64 from int64
+		wantRat   bool   // promoted to *big.Rat from *big.Int
+		floatErr  string // error parsing as int64 or float64
+		ratErr    bool   // a cell is neither an integer nor a fraction
+	}{
+		{cells: []string{"1", "-2"}},
+		{cells: []string{"1", "1.5"}, wantFloat: true, wantRat: true},
+		{cells: []string{"2e3", "1"}, wantFloat: true, wantRat: true},
+		{cells: []string{"1/3", "1"}, wantRat: true, floatErr: "non-int values in matrix. row 1, col 1"},
+		{cells: []string{"9223372036854775808", "-1"}, floatErr: "overflow"},
+		{cells: []string{"99999999999999999999", "1.5"}, wantFloat: true, wantRat: true},
+		{cells: []string{"1.5", "-99999999999999999999"}, wantFloat: true, wantRat: true},
+		{cells: []string{"1.5", "six"}, floatErr: "non-float values in matrix. row 1, col 2", ratErr: true},
+		{cells: []string{"six", "1.5"}, floatErr: "non-int values in matrix. row 1, col 1", ratErr: true},
+	}
+	for _, tc := range tests {
+		m := &Matrix[string]{Data: [][]string{tc.cells}, Rows: 1, Cols: len(tc.cells)}
+
+		ints, floats, err := ParsePromoted[int64, float64](m)
+		switch {
+		case tc.floatErr != "":
+			if err == nil || !strings.Contains(err.Error(), tc.floatErr) {
+				t.Fatalf("%v: expected error containing %q, got %v", tc.cells, tc.floatErr, err)
+			}
+		case err != nil || (floats != nil) != tc.wantFloat || (ints != nil) == tc.wantFloat:
+			t.Fatalf("%v: ParsePromoted[int64, float64] promoted = %v, %v", tc.cells, floats != nil, err)
+		}
+
+		bigs, rats, err := ParsePromoted[*big.Int, *big.Rat](m)
+		if tc.ratErr {
+			if err == nil {
+				t.Fatalf("%v: expected a non-numeric cell error", tc.cells)
+			}
+			continue
+		}
+		if err != nil || (rats != nil) != tc.wantRat || (bigs != nil) == tc.wantRat {
+			t.Fatalf("%v: ParsePromoted[*big.Int, *big.Rat] promoted = %v, %v", tc.cells, rats != nil, err)
+		}
+	}
+
+	// a promoting cell in a later matrix promotes them all
+	_, floats, err := ParsePromoted[int64, float64](&Matrix[string]{Data: [][]string{{"1"}}, Rows: 1, Cols: 1}, &Matrix[string]{Data: [][]string{{"0.5"}}, Rows: 1, Cols: 1})
+	if err != nil || len(floats) != 2 || floats[0].Data[0][0] != 1 || floats[1].Data[0][0] != 0.5 {
+		t.Fatalf("expected both matrices as floats, got %v %v", floats, err)
+	}
+}
+
+// TestScanPromoted checks streamed reductions carry over into the wider type at
+// the first promoting cell, whatever came before it, and match the in-memory result.
+func TestScanPromoted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		wantSum  string
+		wantProd string
+		wantErr  error
+		wantCell *NonNumericCellError
+	}{
+		{name: "ints", input: "1,2\n3,4\n", wantSum: "10", wantProd: "24"},
+		{name: "decimal", input: "1,2\n3,1.5\n", wantSum: "7.5", wantProd: "9"},
+		{name: "scientific first", input: "2e3,1\n", wantSum: "2001", wantProd: "2000"},
+		{name: "overflow then decimal", input: "9223372036854775807,9223372036854775807\n0.5,1\n", wantSum: "1.8446744073709552e+19", wantProd: "4.253529586511731e+37"},
+		{name: "overflow", input: "9223372036854775807,9223372036854775807\n", wantErr: ErrOverflow},
+		{name: "cell too large", input: "9223372036854775808,-1\n", wantErr: ErrOverflow},
+		{name: "cell too large then decimal", input: "99999999999999999999,1\n0.5,1\n", wantSum: "1e+20", wantProd: "5e+19"},
+		{name: "bad cell", input: "1,x\n", wantCell: &NonNumericCellError{Row: 1, Col: 2, Value: "x", Type: "int"}},
+		{name: "float overflow", input: "1e308,1e308\n", wantErr: ErrOverflow},
+		{name: "bad cell after float overflow", input: "1e308,1e308\nx,1\n", wantCell: &NonNumericCellError{Row: 2, Col: 1, Value: "x", Type: "float"}},
+		{name: "nan", input: "1,nan\n", wantCell: &NonNumericCellError{Row: 1, Col: 2, Value: "nan", Type: "int"}},
+		{name: "infinity", input: "1.5,-Inf\n", wantCell: &NonNumericCellError{Row: 1, Col: 2, Value: "-Inf", Type: "float"}},
+		{name: "bad cell after decimal", input: "1.5\nx\n", wantCell: &NonNumericCellError{Row: 2, Col: 1, Value: "x", Type: "float"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, sumErr := ScanAddPromoted[int64, float64](context.Background(), NewScanner(strings.NewReader(tt.input), Dialect{}, Limits{}))
+			prod, prodErr := ScanMultiplyPromoted[int64, float64](context.Background(), NewScanner(strings.NewReader(tt.input), Dialect{}, Limits{}))
+
+			for _, err := range []error{sumErr, prodErr} {
+				switch {
+				case tt.wantCell != nil:
+					var cellErr *NonNumericCellError
+					if !errors.As(err, &cellErr) || *cellErr != *tt.wantCell {
+						t.Fatalf("expected %v, got %v", tt.wantCell, err)
+					}
+				case tt.wantErr != nil:
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("expected %v, got %v", tt.wantErr, err)
+					}
+				case err != nil:
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if tt.wantSum != "" && (sum != tt.wantSum || prod != tt.wantProd) {
+				t.Fatalf("want sum %s product %s, got %s and %s", tt.wantSum, tt.wantProd, sum, prod)
+			}
+		})
+	}
+
+	exact, err := ScanAddPromoted[*big.Int, *big.Rat](context.Background(), NewScanner(strings.NewReader("9223372036854775807,1\n1/2,0.25\n"), Dialect{}, Limits{}))
+	if err != nil || exact != "36893488147419103235/4" {
+		t.Fatalf("expected exact promoted sum, got %v %v", exact, err)
+	}
+}
@@ -0,0 +1,275 @@
+package matrix
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+	This file has the streaming path, for matrices too large to hold whole.
+	A Scanner reads csv one row at a time, and the Scan... functions compute
+	row-wise operations (echo, flatten, sum, product) as the rows go by.
+*/
+
+// Scanner reads a csv matrix one row at a time, checking shape and Limits as it goes.
+// Use it like bufio.Scanner:
+//
+//	for s.Scan() {
+//		row := s.Row()
+//	}
+//	if err := s.Err(); err != nil {
+type Scanner struct {
+	src     io.Reader
+	dialect Dialect
+	reader  *csv.Reader // created by the first Scan, once the dialect is known
+	limits  Limits
+	header  []string
+	label   string
+	row     []string
+	rows    int
+	cols    int
+	err     error
+}
+
+// Returns a Scanner reading csv written in dialect d from r.
+func NewScanner(r io.Reader, d Dialect, limits Limits) *Scanner {
+	return &Scanner{src: r, dialect: d, limits: limits}
+}
+
+// Advances to the next row, returning false at the end of input or on the first error.
+// Rows must all have as many columns as the first, the header row included.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.reader == nil && !s.start() {
+		return false
+	}
+	record, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return false
+	}
+	if err != nil {
+		s.err = fmt.Errorf("error: %w", err)
+		return false
+	}
+	if err := s.limits.CheckRow(s.rows+1, record); err != nil {
+		s.err = err
+		return false
+	}
+
+	// Call to sanitize row
+	cleanRow(record)
+	if s.dialect.Labels {
+		if len(record) < 2 {
+			s.err = fmt.Errorf("error: row %d has a label but no cells", s.rows+1)
+			return false
+		}
+		s.label, record = record[0], record[1:]
+	}
+	s.row, s.cols = record, len(record)
+	s.rows++
+	return true
+}
+
+// Sets up the csv reader for the dialect, sniffing the delimiter if it is not
+// given, and reads the header row if there is one.
+func (s *Scanner) start() bool {
+	if err := s.dialect.Validate(); err != nil {
+		s.err = err
+		return false
+	}
+	br := bufio.NewReaderSize(s.src, sniffSize)
+	d := s.dialect.prepare(br)
+	s.dialect = d
+
+	s.reader = csv.NewReader(br)
+	s.reader.Comma, s.reader.Comment, s.reader.LazyQuotes = d.Delimiter, d.Comment, d.LazyQuotes
+	if !d.Header {
+		s.reader.ReuseRecord = true
+		return true
+	}
+
+	header, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return false
+	}
+	if err != nil {
+		s.err = fmt.Errorf("error: %w", err)
+		return false
+	}
+	if err := s.limits.CheckRow(1, header); err != nil {
+		s.err = err
+		return false
+	}
+	cleanRow(header)
+	if d.Labels {
+		header = header[1:] // the top left cell, naming neither rows nor columns
+	}
+	s.header = header
+	s.reader.ReuseRecord = true
+	return true
+}
+
+// Returns the column labels from the header row, trimmed, once Scan has been
+// called on a Dialect with Header set. nil otherwise.
+func (s *Scanner) Header() []string {
+	return s.header
+}
+
+// Returns the current row's label, trimmed, on a Dialect with Labels set.
+func (s *Scanner) Label() string {
+	return s.label
+}
+
+// Returns the dialect being read, its delimiter sniffed once Scan has been called.
+func (s *Scanner) Dialect() Dialect {
+	return s.dialect
+}
+
+// Returns the current row, trimmed and without its label. It is overwritten by
+// the next call to Scan.
+func (s *Scanner) Row() []string {
+	return s.row
+}
+
+// Returns the number of rows scanned so far, the current row's 1-based index.
+func (s *Scanner) Rows() int {
+	return s.rows
+}
+
+// Returns the number of columns in each row.
+func (s *Scanner) Cols() int {
+	return s.cols
+}
+
+// Returns the first error met while scanning, nil at a clean end of input.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Returns the error ending a scan: Err, or ErrEmptyMatrix if there were no rows.
+func (s *Scanner) end() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.rows == 0 {
+		return ErrEmptyMatrix
+	}
+	return nil
+}
+
+// Writes each row to w as it is scanned, in Echo's format, labels included.
+// Returns ctx.Err() if ctx is done before the last row.
+func ScanEcho(ctx context.Context, s *Scanner, w io.Writer) error {
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.Rows() == 1 && s.Header() != nil {
+			var b strings.Builder
+			writeHeader(&b, s.Header(), s.Dialect().Labels)
+			if _, err := io.WriteString(w, b.String()); err != nil {
+				return err
+			}
+		}
+		if s.Dialect().Labels {
+			if _, err := io.WriteString(w, csvField(s.Label())+","); err != nil {
+				return err
+			}
+		}
+		if err := writeRow(w, s.Row(), "\n"); err != nil {
+			return err
+		}
+	}
+	return s.end()
+}
+
+// Writes every cell to w as it is scanned, in Flatten's format.
+// Returns ctx.Err() if ctx is done before the last row.
+func ScanFlatten(ctx context.Context, s *Scanner, w io.Writer) error {
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sep := ","
+		if s.Rows() == 1 {
+			sep = ""
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		if err := writeRow(w, s.Row(), ""); err != nil {
+			return err
+		}
+	}
+	return s.end()
+}
+
+// Returns the sum of every cell, parsed into T as it is scanned.
+// Errors as Add, and with ctx.Err() if ctx is done before the last row.
+func ScanAdd[T Number](ctx context.Context, s *Scanner) (T, error) {
+	a := arith[T]()
+	return scanReduce(ctx, s, a.zero(), a.add)
+}
+
+// Returns the product of every cell, parsed into T as it is scanned.
+// Errors as Multiply, and with ctx.Err() if ctx is done before the last row.
+func ScanMultiply[T Number](ctx context.Context, s *Scanner) (T, error) {
+	a := arith[T]()
+	return scanReduce(ctx, s, a.one(), a.mul)
+}
+
+// Folds combine over every cell, starting from acc.
+// After an overflow the remaining cells are still parsed, so a bad cell is
+// reported as it would be by the in-memory operations, which parse first.
+func scanReduce[T Number](ctx context.Context, s *Scanner, acc T, combine func(a, b T) (T, bool)) (T, error) {
+	a := arith[T]()
+	overflow := false
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return a.zero(), err
+		}
+		for j, cell := range s.Row() {
+			v, err := a.parse(cell)
+			if err != nil {
+				return a.zero(), &NonNumericCellError{Row: s.Rows(), Col: j + 1, Value: cell, Type: a.name}
+			}
+			if overflow {
+				continue
+			}
+			var ok bool
+			if acc, ok = combine(acc, v); !ok {
+				overflow = true
+			}
+		}
+	}
+	if err := s.end(); err != nil {
+		return a.zero(), err
+	}
+	if overflow {
+		return a.zero(), ErrOverflow
+	}
+	return acc, nil
+}
+
+// Writes the cells of row comma separated, quoted as needed, followed by end.
+func writeRow(w io.Writer, row []string, end string) error {
+	for j, cell := range row {
+		if j > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, csvField(cell)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, end)
+	return err
+}
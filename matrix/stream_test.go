@@ -0,0 +1,123 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestScanner checks rows are trimmed, counted and checked for shape as they are read.
+func TestScanner(t *testing.T) {
+	t.Parallel()
+
+	s := NewScanner(strings.NewReader("1, 2\n 3,4\n5,6,7\n"), Dialect{}, Limits{})
+	var got []string
+	for s.Scan() {
+		got = append(got, strings.Join(s.Row(), "|"))
+	}
+
+	if want := "1|2 3|4"; strings.Join(got, " ") != want {
+		t.Fatalf("rows mismatch: want %q got %q", want, strings.Join(got, " "))
+	}
+	if s.Rows() != 2 || s.Cols() != 2 {
+		t.Fatalf("expected 2x2 scanned, got %dx%d", s.Rows(), s.Cols())
+	}
+	if err := s.Err(); err == nil || !strings.Contains(err.Error(), "wrong number of fields") {
+		t.Fatalf("expected ragged row error, got %v", err)
+	}
+}
+
+// TestScanWriters checks echo and flatten stream the same output as the
+// in-memory Echo and Flatten.
+func TestScanWriters(t *testing.T) {
+	t.Parallel()
+
+	input := "1,2,3\n4, 5,6\n7,8,9\n"
+	m, err := FromRecords[string]([][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7", "8", "9"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var echo, flat strings.Builder
+	if err := ScanEcho(context.Background(), NewScanner(strings.NewReader(input), Dialect{}, Limits{}), &echo); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ScanFlatten(context.Background(), NewScanner(strings.NewReader(input), Dialect{}, Limits{}), &flat); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if echo.String() != m.Echo() {
+		t.Fatalf("echo mismatch.\nwant:\n%s\ngot:\n%s", m.Echo(), echo.String())
+	}
+	if flat.String() != m.Flatten() {
+		t.Fatalf("flatten mismatch: want %q got %q", m.Flatten(), flat.String())
+	}
+}
+
+// TestScanReductions checks streamed sums and products, and that errors match
+// the in-memory operations, including a bad cell after an overflow.
+func TestScanReductions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		wantSum  string
+		wantProd string
+		wantErr  error
+		wantCell *NonNumericCellError
+	}{
+		{name: "ints", input: "1,2,3\n4,5,6\n", wantSum: "21", wantProd: "720"},
+		{name: "empty", input: "", wantErr: ErrEmptyMatrix},
+		{name: "bad cell", input: "1,2\n3,x\n", wantCell: &NonNumericCellError{Row: 2, Col: 2, Value: "x", Type: "int"}},
+		{name: "overflow", input: "9223372036854775807,9223372036854775807\n", wantErr: ErrOverflow},
+		{name: "bad cell after overflow", input: "9223372036854775807,9223372036854775807\nx,1\n", wantCell: &NonNumericCellError{Row: 2, Col: 1, Value: "x", Type: "int"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, sumErr := ScanAdd[int64](context.Background(), NewScanner(strings.NewReader(tt.input), Dialect{}, Limits{}))
+			prod, prodErr := ScanMultiply[int64](context.Background(), NewScanner(strings.NewReader(tt.input), Dialect{}, Limits{}))
+
+			for _, err := range []error{sumErr, prodErr} {
+				switch {
+				case tt.wantCell != nil:
+					var cellErr *NonNumericCellError
+					if !errors.As(err, &cellErr) || *cellErr != *tt.wantCell {
+						t.Fatalf("expected %v, got %v", tt.wantCell, err)
+					}
+				case tt.wantErr != nil:
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("expected %v, got %v", tt.wantErr, err)
+					}
+				case err != nil:
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if tt.wantSum != "" && (Format(sum) != tt.wantSum || Format(prod) != tt.wantProd) {
+				t.Fatalf("want sum %s product %s, got %d and %d", tt.wantSum, tt.wantProd, sum, prod)
+			}
+		})
+	}
+
+	exact, err := ScanAdd[*big.Int](context.Background(), NewScanner(strings.NewReader("9223372036854775807,1\n"), Dialect{}, Limits{}))
+	if err != nil || exact.String() != "9223372036854775808" {
+		t.Fatalf("expected exact big sum, got %v %v", exact, err)
+	}
+}
+
+// TestScanCancelled checks streaming stops with the context's error.
+func TestScanCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ScanAdd[int64](ctx, NewScanner(strings.NewReader("1,2\n"), Dialect{}, Limits{})); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := ScanEcho(ctx, NewScanner(strings.NewReader("1,2\n"), Dialect{}, Limits{}), &strings.Builder{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
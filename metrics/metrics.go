@@ -0,0 +1,338 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	This package has counters, gauges and histograms written in the Prometheus
+	text exposition format, see https://prometheus.io/docs/instrumenting/exposition_formats/
+	Every metric belongs to a Registry and may have labels, each distinct set of
+	label values is its own series:
+		requests := reg.NewCounter("matrix_requests_total", "Requests served.", "op", "status")
+		requests.Inc("transpose", "200")
+	writes
+		# HELP matrix_requests_total Requests served.
+		# TYPE matrix_requests_total counter
+		matrix_requests_total{op="transpose",status="200"} 1
+*/
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds metrics and writes them, sorted by name.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// metric is a registered counter, gauge, histogram or func.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// Adds m under name. Registering a name twice is a programming error and panics.
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// Writes every metric in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Serves the registry, for GET /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// family is the series of one metric, by label values.
+type family[S any] struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*S // keyed by the label values joined with labelSep
+	values map[string][]string
+	newS   func() *S
+}
+
+const labelSep = "\xff"
+
+func newFamily[S any](name, help, typ string, labels []string, newS func() *S) *family[S] {
+	return &family[S]{name: name, help: help, typ: typ, labels: labels, series: map[string]*S{}, values: map[string][]string{}, newS: newS}
+}
+
+// Runs fn on the series for labelValues, creating it at zero. Panics unless
+// there is one value per label.
+func (f *family[S]) with(labelValues []string, fn func(s *S)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", f.name, f.labels, labelValues))
+	}
+	key := strings.Join(labelValues, labelSep)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = f.newS()
+		f.series[key] = s
+		f.values[key] = slices.Clone(labelValues)
+	}
+	fn(s)
+}
+
+// Writes the HELP and TYPE lines, then calls fn for every series sorted by label values.
+func (f *family[S]) write(w *bufio.Writer, fn func(labels string, s *S)) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(labelPairs(f.labels, f.values[key]), f.series[key])
+	}
+}
+
+// Counter is a value that only goes up, eg: requests served.
+type Counter struct {
+	f *family[float64]
+}
+
+// Registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{f: newFamily(name, help, "counter", labels, func() *float64 { return new(float64) })}
+	r.register(name, c)
+	return c
+}
+
+// Adds one to the series for labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Adds v, which must not be negative, to the series for labelValues.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s cannot decrease", c.f.name))
+	}
+	c.f.with(labelValues, func(s *float64) { *s += v })
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.f.write(w, func(labels string, s *float64) {
+		writeSample(w, c.f.name, labels, *s)
+	})
+}
+
+// Gauge is a value that goes up and down, eg: requests in flight.
+type Gauge struct {
+	f *family[float64]
+}
+
+// Registers a gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{f: newFamily(name, help, "gauge", labels, func() *float64 { return new(float64) })}
+	r.register(name, g)
+	return g
+}
+
+// Adds v, which may be negative, to the series for labelValues.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.with(labelValues, func(s *float64) { *s += v })
+}
+
+// Sets the series for labelValues to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.with(labelValues, func(s *float64) { *s = v })
+}
+
+func (g *Gauge) Inc(labelValues ...string) { g.Add(1, labelValues...) }
+func (g *Gauge) Dec(labelValues ...string) { g.Add(-1, labelValues...) }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.f.write(w, func(labels string, s *float64) {
+		writeSample(w, g.f.name, labels, *s)
+	})
+}
+
+// Histogram counts observations into buckets, eg: request latencies.
+type Histogram struct {
+	f       *family[histogramSeries]
+	buckets []float64 // upper bounds, ascending, without +Inf
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative, the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Registers a histogram with the given bucket upper bounds and label names.
+// A +Inf bucket is always added.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+	h := &Histogram{buckets: buckets}
+	h.f = newFamily(name, help, "histogram", labels, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets)+1)}
+	})
+	r.register(name, h)
+	return h
+}
+
+// Records v in the series for labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	i, _ := slices.BinarySearch(h.buckets, v) // first bound >= v, as le is inclusive
+	h.f.with(labelValues, func(s *histogramSeries) {
+		s.counts[i]++
+		s.sum += v
+		s.count++
+	})
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.f.write(w, func(labels string, s *histogramSeries) {
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			writeSample(w, h.f.name+"_bucket", joinLabels(labels, `le="`+formatValue(le)+`"`), float64(cumulative))
+		}
+		writeSample(w, h.f.name+"_sum", labels, s.sum)
+		writeSample(w, h.f.name+"_count", labels, float64(s.count))
+	})
+}
+
+// Returns bounds start, start*factor, ... count of them, for NewHistogram.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// funcMetric is an unlabelled counter or gauge read when written, eg: from a cache's own counters.
+type funcMetric struct {
+	name, help, typ string
+	fn              func() float64
+}
+
+// Registers a counter whose value is fn's, for counts kept elsewhere.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, typ: "counter", fn: fn})
+}
+
+// Registers a gauge whose value is fn's.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, typ: "gauge", fn: fn})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, escapeHelp(m.help), m.name, m.typ)
+	writeSample(w, m.name, "", m.fn())
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatValue(v) + "\n")
+}
+
+// Returns names and values as name="value" pairs, values escaped.
+func labelPairs(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
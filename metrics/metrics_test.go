@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestWriteTo checks the exact exposition of every metric type, sorted by name
+// and label values, with label values escaped.
+func TestWriteTo(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounter("requests_total", "Requests served.", "op", "status")
+	inFlight := reg.NewGauge("in_flight", "Requests being served.\nPer op.", "op")
+	latency := reg.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1}, "op")
+	reg.NewCounterFunc("hits_total", "Cache hits.", func() float64 { return 7 })
+
+	requests.Inc("transpose", "200")
+	requests.Add(2, "add", "413")
+	requests.Inc("transpose", "200")
+	requests.Inc(`we"ird\`, "200")
+	inFlight.Inc("add")
+	inFlight.Inc("add")
+	inFlight.Dec("add")
+	latency.Observe(0.1, "add") // le is inclusive
+	latency.Observe(0.5, "add")
+	latency.Observe(3, "add")
+
+	want := `# HELP hits_total Cache hits.
+# TYPE hits_total counter
+hits_total 7
+# HELP in_flight Requests being served.\nPer op.
+# TYPE in_flight gauge
+in_flight{op="add"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="add",le="0.1"} 1
+latency_seconds_bucket{op="add",le="1"} 2
+latency_seconds_bucket{op="add",le="+Inf"} 3
+latency_seconds_sum{op="add"} 3.6
+latency_seconds_count{op="add"} 3
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{op="add",status="413"} 2
+requests_total{op="transpose",status="200"} 2
+requests_total{op="we\"ird\\",status="200"} 1
+`
+	var b strings.Builder
+	n, err := reg.WriteTo(&b)
+	if err != nil || n != int64(b.Len()) {
+		t.Fatalf("WriteTo returned %d, %v for %d bytes", n, err, b.Len())
+	}
+	if b.String() != want {
+		t.Fatalf("exposition mismatch\nwant:\n%s\ngot:\n%s", want, b.String())
+	}
+}
+
+// TestHandler checks the content type served.
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewGaugeFunc("up", "Always 1.", func() float64 { return 1 })
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("expected content type %q, got %q", ContentType, got)
+	}
+	if !strings.Contains(rec.Body.String(), "up 1\n") {
+		t.Fatalf("expected the gauge, got %q", rec.Body.String())
+	}
+}
+
+// TestMisuse checks programming errors panic rather than write bad exposition.
+func TestMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(reg *Registry)
+	}{
+		{name: "duplicate name", fn: func(reg *Registry) {
+			reg.NewCounter("x", "")
+			reg.NewGauge("x", "")
+		}},
+		{name: "missing label value", fn: func(reg *Registry) {
+			reg.NewCounter("x", "", "op").Inc()
+		}},
+		{name: "negative counter", fn: func(reg *Registry) {
+			reg.NewCounter("x", "").Add(-1)
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected a panic")
+				}
+			}()
+			tc.fn(NewRegistry())
+		})
+	}
+}
+
+// TestConcurrent updates and writes metrics from many goroutines, for the race detector.
+func TestConcurrent(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("c", "", "op")
+	h := reg.NewHistogram("h", "", ExponentialBuckets(1, 4, 4))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				c.Inc("op")
+				h.Observe(float64(i))
+				reg.WriteTo(&strings.Builder{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	var b strings.Builder
+	reg.WriteTo(&b)
+	for _, want := range []string{`c{op="op"} 800`, `h_bucket{le="64"} 520`, "h_count 800"} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+/*
+	This file has the middleware wrapping the mux:
+	- RequestID assigns every request an ID, echoed in the X-Request-ID header
+	- AccessLog writes one structured log line per request
+	- Recover turns a panic into a 500 carrying the request ID
+	Chain them outermost first, see main.go.
+*/
+
+// RequestIDHeader is read from the request, and set on the response, to correlate logs.
+const RequestIDHeader = "X-Request-ID"
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	loggerKey
+	attrsKey
+)
+
+// Middleware wraps a handler with extra behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Wraps h with mws, the first being outermost.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Assigns every request an ID, keeping a sane incoming X-Request-ID or generating one.
+// The ID is set on the response header and available via RequestIDFrom.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// Returns the request ID assigned by RequestID, or "" outside of it.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Returns the request's logger, carrying its request ID, or slog.Default() outside of AccessLog.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Adds attributes to the request's access log line, eg: the parsed matrix size.
+// Does nothing outside of AccessLog.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if a, ok := ctx.Value(attrsKey).(*requestAttrs); ok {
+		a.mu.Lock()
+		a.attrs = append(a.attrs, attrs...)
+		a.mu.Unlock()
+	}
+}
+
+// Logs one line per request with method, path, status, bytes written, latency,
+// the request ID and any attributes added by handlers via AddAttrs. A response
+// aborted with http.ErrAbortHandler, eg: a stream failing after its status was
+// sent, is logged as a warning with aborted=true.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLogger := logger.With(slog.String("request_id", RequestIDFrom(r.Context())))
+			attrs := &requestAttrs{}
+
+			ctx := context.WithValue(r.Context(), loggerKey, reqLogger)
+			ctx = context.WithValue(ctx, attrsKey, attrs)
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			defer func() {
+				v := recover()
+				attrs.mu.Lock()
+				defer attrs.mu.Unlock()
+				all := append([]slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", rec.status),
+					slog.Int64("bytes", rec.bytes),
+					slog.Duration("latency", time.Since(start)),
+					slog.String("remote", r.RemoteAddr),
+				}, attrs.attrs...)
+				level := slog.LevelInfo
+				if v == http.ErrAbortHandler {
+					level, all = slog.LevelWarn, append(all, slog.Bool("aborted", true))
+				}
+				reqLogger.LogAttrs(r.Context(), level, "request", all...)
+				if v != nil {
+					panic(v)
+				}
+			}()
+
+			next.ServeHTTP(rec, r.WithContext(ctx))
+		})
+	}
+}
+
+// Recovers from panics in next, logging the panic and stack and responding 500
+// with a problem body naming the request ID.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// net/http uses this panic to abort a response on purpose
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			id := RequestIDFrom(r.Context())
+			Logger(r.Context()).Error("panic", slog.Any("panic", v), slog.String("stack", string(debug.Stack())))
+
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]any{
+				"type":       "urn:matrix:problem:internal",
+				"title":      "Internal server error",
+				"status":     http.StatusInternalServerError,
+				"detail":     fmt.Sprintf("error: internal server error. quote request id '%s' when reporting this", id),
+				"instance":   r.URL.Path,
+				"code":       "internal",
+				"request_id": id,
+			})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestAttrs collects attributes handlers add to the access log line.
+type requestAttrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// responseRecorder captures the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, eg: to flush.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Accepts client supplied IDs of up to 128 printable ASCII characters.
+// Anything else is replaced, so IDs are safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a random 16 character hex ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
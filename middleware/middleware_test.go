@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestLogger returns a JSON logger writing into buf so tests can decode
+// each log line.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, nil))
+}
+
+// decodeLines parses every JSON log line written to buf.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// TestRequestID checks a sane incoming ID is kept, and a missing or unsafe
+// one is replaced, with the ID echoed on the response and in the context.
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "kept", incoming: "abc-123", keep: true},
+		{name: "missing", incoming: ""},
+		{name: "unsafe", incoming: "abc\n123"},
+		{name: "too long", incoming: strings.Repeat("a", 129)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen string
+			h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFrom(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tc.incoming)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("expected matching non-empty ids, header %q context %q", got, seen)
+			}
+			if tc.keep != (got == tc.incoming) {
+				t.Fatalf("incoming %q, got %q", tc.incoming, got)
+			}
+		})
+	}
+}
+
+// TestAccessLog checks the access line carries the request fields, the bytes
+// written, the request ID and attributes added by the handler.
+func TestAccessLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddAttrs(r.Context(), slog.String("matrix_size", "3x3"))
+		Logger(r.Context()).Info("working")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}), RequestID, AccessLog(newTestLogger(buf)))
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected handler and access lines, got %d", len(lines))
+	}
+	if lines[0]["request_id"] != "req-1" {
+		t.Fatalf("expected handler line to carry request id, got %v", lines[0])
+	}
+
+	access := lines[1]
+	want := map[string]any{"msg": "request", "method": "POST", "path": "/echo", "status": 418.0, "bytes": 5.0, "request_id": "req-1", "matrix_size": "3x3"}
+	for key, v := range want {
+		if access[key] != v {
+			t.Fatalf("expected %s=%v, got %v in %v", key, v, access[key], access)
+		}
+	}
+	if _, ok := access["latency"]; !ok {
+		t.Fatalf("expected latency in %v", access)
+	}
+}
+
+// TestAccessLogAborted checks a response aborted after its status was sent is
+// logged as aborted, and the abort carries on to net/http.
+func TestAccessLogAborted(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1,2\n"))
+		panic(http.ErrAbortHandler)
+	}), RequestID, AccessLog(newTestLogger(buf)), Recover)
+
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expected the abort to carry on, got %v", v)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/echo", nil))
+	}()
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 1 || lines[0]["aborted"] != true || lines[0]["status"] != 200.0 || lines[0]["level"] != "WARN" {
+		t.Fatalf("expected one aborted access line, got %v", lines)
+	}
+}
+
+// TestRecover checks a panic becomes a logged 500 whose body and log line
+// both name the request ID.
+func TestRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), RequestID, AccessLog(newTestLogger(buf)), Recover)
+
+	req := httptest.NewRequest(http.MethodGet, "/echo", nil)
+	req.Header.Set(RequestIDHeader, "req-2")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body %q: %v", rec.Body.String(), err)
+	}
+	if body["request_id"] != "req-2" || !strings.Contains(body["detail"].(string), "req-2") {
+		t.Fatalf("expected request id in body, got %v", body)
+	}
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 2 || lines[0]["msg"] != "panic" || lines[0]["panic"] != "boom" || lines[0]["request_id"] != "req-2" {
+		t.Fatalf("expected panic line with request id, got %v", lines)
+	}
+	if lines[1]["status"] != 500.0 {
+		t.Fatalf("expected access line with status 500, got %v", lines[1])
+	}
+}
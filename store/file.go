@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"league_challenge/matrix"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File is a Store keeping each matrix as <id>.csv in a directory, surviving restarts.
+// Files are written to a temporary name and renamed into place, so a crash never
+// leaves a half written matrix under an ID. A labelled matrix is written with its
+// header row and label column, after a labelsMarker line naming which it has.
+type File struct {
+	dir string
+}
+
+// Returns a File store in dir, creating the directory if needed.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error: cannot create store directory. %w", err)
+	}
+	return &File{dir: dir}, nil
+}
+
+func (s *File) Put(m *matrix.Matrix[string]) (string, error) {
+	tmp, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("error: cannot store matrix. %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if m.Labelled() {
+		fmt.Fprintln(tmp, labelsMarker+strings.Join(labelKinds(m), ","))
+	}
+	w := csv.NewWriter(tmp)
+	for i, record := range labelledRecords(m) {
+		// csv.Writer writes a lone empty cell as a blank line, which csv.Reader
+		// skips, and a first cell starting with labelsMarker as the marker line
+		if (len(record) == 1 && record[0] == "") || (i == 0 && len(record) > 0 && strings.HasPrefix(record[0], labelsMarker)) {
+			w.Flush()
+			fmt.Fprintln(tmp, quoted(record))
+			continue
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := errors.Join(w.Error(), tmp.Close()); err != nil {
+		return "", fmt.Errorf("error: cannot store matrix. %w", err)
+	}
+
+	id := newID()
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		return "", fmt.Errorf("error: cannot store matrix. %w", err)
+	}
+	return id, nil
+}
+
+func (s *File) Get(id string) (*matrix.Matrix[string], error) {
+	if !validID(id) {
+		return nil, notFound(id)
+	}
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: cannot read matrix. %w", err)
+	}
+	defer f.Close()
+
+	d := matrix.Dialect{Delimiter: ','}
+	r := bufio.NewReader(f)
+	if first, _ := r.Peek(len(labelsMarker)); string(first) == labelsMarker {
+		line, _ := r.ReadString('\n')
+		for _, kind := range strings.Split(strings.TrimSpace(strings.TrimPrefix(line, labelsMarker)), ",") {
+			d.Header = d.Header || kind == "cols"
+			d.Labels = d.Labels || kind == "rows"
+		}
+	}
+	return matrix.ReadMatrix[string](r, d, matrix.Limits{})
+}
+
+func (s *File) Delete(id string) error {
+	if !validID(id) {
+		return notFound(id)
+	}
+	err := os.Remove(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return notFound(id)
+	}
+	return err
+}
+
+func (s *File) path(id string) string {
+	return filepath.Join(s.dir, id+".csv")
+}
+
+// labelsMarker starts the first line of a labelled matrix's file, eg: "#labels=rows,cols"
+const labelsMarker = "#labels="
+
+// Returns "rows" and/or "cols", the labels m has.
+func labelKinds(m *matrix.Matrix[string]) []string {
+	var kinds []string
+	if m.RowLabels != nil {
+		kinds = append(kinds, "rows")
+	}
+	if m.ColLabels != nil {
+		kinds = append(kinds, "cols")
+	}
+	return kinds
+}
+
+// Returns m's cells as csv records, led by its header row and label column if it has them.
+func labelledRecords(m *matrix.Matrix[string]) [][]string {
+	if !m.Labelled() {
+		return m.Data
+	}
+	var records [][]string
+	if m.ColLabels != nil {
+		header := m.ColLabels
+		if m.RowLabels != nil {
+			header = append([]string{""}, header...)
+		}
+		records = append(records, header)
+	}
+	for i, row := range m.Data {
+		if m.RowLabels != nil {
+			row = append([]string{m.RowLabels[i]}, row...)
+		}
+		records = append(records, row)
+	}
+	return records
+}
+
+// Returns record as a csv line with every field quoted.
+func quoted(record []string) string {
+	fields := make([]string, len(record))
+	for i, field := range record {
+		fields[i] = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+	}
+	return strings.Join(fields, ",")
+}
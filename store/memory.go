@@ -0,0 +1,45 @@
+package store
+
+import (
+	"league_challenge/matrix"
+	"sync"
+)
+
+// Memory is a Store holding matrices in a map, lost on restart.
+type Memory struct {
+	mu       sync.RWMutex
+	matrices map[string]*matrix.Matrix[string]
+}
+
+// Returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{matrices: map[string]*matrix.Matrix[string]{}}
+}
+
+func (s *Memory) Put(m *matrix.Matrix[string]) (string, error) {
+	id := newID()
+	s.mu.Lock()
+	s.matrices[id] = m
+	s.mu.Unlock()
+	return id, nil
+}
+
+func (s *Memory) Get(id string) (*matrix.Matrix[string], error) {
+	s.mu.RLock()
+	m, ok := s.matrices[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, notFound(id)
+	}
+	return m, nil
+}
+
+func (s *Memory) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.matrices[id]; !ok {
+		return notFound(id)
+	}
+	delete(s.matrices, id)
+	return nil
+}
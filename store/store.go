@@ -0,0 +1,50 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"league_challenge/matrix"
+)
+
+/*
+	This package keeps uploaded matrices under generated IDs, so a matrix is
+	uploaded once and referenced by later requests, eg: /det?id=3f2a9c0b1d4e5f60
+	Memory keeps them for the life of the process, File in a directory on disk.
+*/
+
+// ErrNotFound is returned for an ID the store does not hold.
+var ErrNotFound = errors.New("error: matrix not found")
+
+// Store keeps matrices under IDs it generates.
+// Implementations are safe for concurrent use.
+type Store interface {
+	// Put stores m and returns its new ID.
+	Put(m *matrix.Matrix[string]) (string, error)
+	// Get returns the matrix stored under id, or ErrNotFound.
+	Get(id string) (*matrix.Matrix[string], error)
+	// Delete removes the matrix stored under id, or returns ErrNotFound.
+	Delete(id string) error
+}
+
+// Returns ErrNotFound naming id.
+func notFound(id string) error {
+	return fmt.Errorf("%w. no matrix with id '%s'", ErrNotFound, id)
+}
+
+// Returns a random 16 character hex ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Reports whether id could have come from newID, so IDs are safe to use as file names.
+func validID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
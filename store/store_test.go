@@ -0,0 +1,123 @@
+package store
+
+import (
+	"errors"
+	"league_challenge/matrix"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestStores runs the same put, get and delete cycle against every implementation.
+func TestStores(t *testing.T) {
+	file, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stores := map[string]Store{"memory": NewMemory(), "file": file}
+
+	m, err := matrix.FromRecords[string]([][]string{{"1", "2"}, {"a,b", ""}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	labelled := func(rows, cols []string) *matrix.Matrix[string] {
+		l := *m
+		l.RowLabels, l.ColLabels = rows, cols
+		return &l
+	}
+	variants := []*matrix.Matrix[string]{
+		labelled([]string{"x", `say "y"`}, []string{"", "b"}),
+		labelled([]string{"#labels=", "y"}, nil),
+		labelled(nil, []string{"a", "b,c"}),
+	}
+
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			id, err := s.Put(m)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !validID(id) {
+				t.Fatalf("expected a 16 character hex id, got %q", id)
+			}
+
+			got, err := s.Get(id)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, m) {
+				t.Fatalf("stored matrix mismatch: want %v got %v", m, got)
+			}
+			for _, v := range variants {
+				id, _ := s.Put(v)
+				if got, err := s.Get(id); err != nil || !reflect.DeepEqual(got, v) {
+					t.Fatalf("stored labelled matrix mismatch: want %+v got %+v, %v", v, got, err)
+				}
+			}
+
+			if err := s.Delete(id); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound after delete, got %v", err)
+			}
+			if err := s.Delete(id); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+			}
+			for _, bad := range []string{"", "missing", "../../etc/passwd", "0123456789abcdef"} {
+				if _, err := s.Get(bad); !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound for %q, got %v", bad, err)
+				}
+			}
+		})
+	}
+}
+
+// TestFileSurvivesRestart checks a matrix stored by one File is read back by a
+// new File over the same directory, and no temporary files are left behind.
+func TestFileSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := NewFile(dir)
+	m, _ := matrix.FromRecords[string]([][]string{{"1", "2", "3"}})
+	id, err := first.Put(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	second, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := second.Get(id)
+	if err != nil || got.Echo() != "1,2,3\n" {
+		t.Fatalf("expected the stored matrix after restart, got %v %v", got, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != id+".csv" {
+		t.Fatalf("expected only %s.csv in %s, got %v", id, filepath.Base(dir), entries)
+	}
+}
+
+// TestFileRoundTrip checks rows of a single empty cell, or label, are read back
+// rather than skipped as blank lines, and a first cell like the labels marker
+// is read back as a cell.
+func TestFileRoundTrip(t *testing.T) {
+	s, _ := NewFile(t.TempDir())
+	m, _ := matrix.FromRecords[string]([][]string{{"1"}, {""}, {"3"}})
+	labelled, _ := matrix.FromRecords[string]([][]string{{""}, {"2"}})
+	labelled.ColLabels = []string{""}
+	marker, _ := matrix.FromRecords[string]([][]string{{labelsMarker + "rows", `say "hi"`}, {"1", "2"}})
+
+	for _, want := range []*matrix.Matrix[string]{m, labelled, marker} {
+		id, err := s.Put(want)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := s.Get(id)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v (%v)", want, got, err)
+		}
+	}
+}